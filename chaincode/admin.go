@@ -0,0 +1,481 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 供应商管理员发起管理员变更提案，发起者自动批准，批准人数达到法定人数时立即执行
+// 参数： 供应商名称 变更类型 变更参数
+// 变更参数： Add 为新管理员身份ID；Rotate 为旧管理员身份ID 新管理员身份ID；Revoke 为管理员身份ID；SetQuorum 为新的法定人数
+// 返回： 管理员变更提案对象
+func (t *MedicalSystem) supplierProposeAdminChange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 3 && len(args) != 4 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3 or 4", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	supplierName := args[0]
+	action := args[1]
+
+	// 根据变更类型构造提案
+	proposal := lib.AdminProposal{
+		ProposalID:   stub.GetTxID(),
+		SupplierName: supplierName,
+		Action:       action,
+		State:        lib.ProposalPending,
+	}
+	switch action {
+	case lib.AdminAdd, lib.AdminRevoke:
+		if len(args) != 3 {
+			return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3", Payload: nil}
+		}
+		proposal.Admin = args[2]
+	case lib.AdminRotate:
+		if len(args) != 4 {
+			return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 4", Payload: nil}
+		}
+		proposal.Admin = args[2]
+		proposal.NewAdmin = args[3]
+	case lib.AdminSetQuorum:
+		if len(args) != 3 {
+			return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3", Payload: nil}
+		}
+		quorum, err := strconv.Atoi(args[2]) // 新的法定人数
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+		}
+		proposal.Quorum = quorum
+	default:
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The action must be one of %s, %s, %s, %s", lib.AdminAdd, lib.AdminRotate, lib.AdminRevoke, lib.AdminSetQuorum), Payload: nil}
+	}
+
+	// 读取账本，获取供应商
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	// 验证调用者为该供应商的管理员
+	err = checkSupplierAdmin(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if supplier.State != lib.Active {
+		return pb.Response{Status: 400, Message: "The supplier has been deactivated", Payload: nil}
+	}
+	// 提前验证提案能否在当前管理员列表上执行
+	if _, err = applyAdminProposal(supplier, &proposal); err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid proposal: %s", err), Payload: nil}
+	}
+
+	_, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	createTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction timestamp error: %s", err), Payload: nil}
+	}
+	proposal.Proposer = callerID
+	proposal.Approvals = []string{callerID}
+	proposal.CreateTime = createTime
+	proposal.Deadline = createTime.AddDate(0, 0, lib.AdminProposalDays)
+
+	return approveAdminProposal(stub, supplier, &proposal)
+}
+
+// 供应商管理员批准管理员变更提案，批准人数达到法定人数时立即执行，超过批准期限的提案不能再批准
+// 参数： 供应商名称 提案ID
+// 返回： 管理员变更提案对象
+func (t *MedicalSystem) supplierApproveAdminChange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+	proposalID := args[1]
+
+	// 读取账本，获取供应商
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	// 验证调用者为该供应商的管理员
+	err = checkSupplierAdmin(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if supplier.State != lib.Active {
+		return pb.Response{Status: 400, Message: "The supplier has been deactivated", Payload: nil}
+	}
+
+	// 读取账本，获取提案
+	proposal, err := getAdminProposal(stub, supplierName, proposalID)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get admin proposal error: %s", err), Payload: nil}
+	} else if proposal == nil {
+		return pb.Response{Status: 400, Message: "The proposal does not exist", Payload: nil}
+	}
+	resp := checkPendingProposal(stub, proposal)
+	if resp != nil {
+		return *resp
+	}
+
+	_, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	if utils.ContainsName(proposal.Approvals, callerID) {
+		return pb.Response{Status: 400, Message: "The caller has already approved this proposal", Payload: nil}
+	}
+	proposal.Approvals = append(proposal.Approvals, callerID)
+
+	return approveAdminProposal(stub, supplier, proposal)
+}
+
+// 供应商管理员撤回自己发起的、尚未执行的管理员变更提案，包括已超过批准期限的提案
+// 参数： 供应商名称 提案ID
+// 返回： 管理员变更提案对象
+func (t *MedicalSystem) supplierCancelAdminChange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+	proposalID := args[1]
+
+	// 读取账本，获取供应商
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	// 验证调用者为该供应商的管理员
+	err = checkSupplierAdmin(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	// 读取账本，获取提案
+	proposal, err := getAdminProposal(stub, supplierName, proposalID)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get admin proposal error: %s", err), Payload: nil}
+	} else if proposal == nil {
+		return pb.Response{Status: 400, Message: "The proposal does not exist", Payload: nil}
+	}
+	if proposal.State != lib.ProposalPending {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The proposal has already been %s", strings.ToLower(proposal.State)), Payload: nil}
+	}
+	// 只有发起者可以撤回提案
+	_, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	if callerID != proposal.Proposer {
+		return pb.Response{Status: 403, Message: "Permission denied: only the proposer can cancel the proposal", Payload: nil}
+	}
+	proposal.State = lib.ProposalCancelled
+
+	// 写入账本
+	err = putAdminProposal(stub, proposal)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put admin proposal error: %s", err), Payload: nil}
+	}
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+	return pb.Response{Status: 200, Message: "Cancel successful", Payload: proposalJSON}
+}
+
+// 查看供应商管理员变更提案
+// 参数： 供应商名称
+// 返回： 按发起时间排序的提案列表，超过批准期限仍未执行的提案显示为已过期
+func (t *MedicalSystem) supplierViewAdminProposals(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction timestamp error: %s", err), Payload: nil}
+	}
+
+	// 通过组合键前缀查询该供应商的全部提案
+	iterator, err := stub.GetStateByPartialCompositeKey(lib.ObjectTypeAdminProposal, []string{supplierName})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("GetStateByPartialCompositeKey error: %s", err), Payload: nil}
+	}
+	defer iterator.Close()
+	proposalsList := make([]lib.AdminProposal, 0)
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Iterator error: %s", err), Payload: nil}
+		}
+		var proposal lib.AdminProposal
+		err = json.Unmarshal(kv.Value, &proposal)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		}
+		if proposal.State == lib.ProposalPending && proposalExpired(&proposal, now) {
+			proposal.State = lib.ProposalExpired
+		}
+		proposalsList = append(proposalsList, proposal)
+	}
+	sort.Slice(proposalsList, func(i, j int) bool {
+		if proposalsList[i].CreateTime.Equal(proposalsList[j].CreateTime) {
+			return proposalsList[i].ProposalID < proposalsList[j].ProposalID
+		}
+		return proposalsList[i].CreateTime.Before(proposalsList[j].CreateTime)
+	})
+	// 序列化提案列表
+	proposalsListJSON, err := json.Marshal(proposalsList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: proposalsListJSON}
+}
+
+// 查看供应商管理员变更记录，可据此确定任意时刻拥有审核权限的管理员
+// 参数： 供应商名称
+// 返回： 按时间顺序排列的变更记录列表
+func (t *MedicalSystem) supplierViewAdminChanges(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+
+	// 通过组合键前缀查询该供应商的全部变更记录，结果按序号排序
+	iterator, err := stub.GetStateByPartialCompositeKey(lib.ObjectTypeAdminChange, []string{supplierName})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("GetStateByPartialCompositeKey error: %s", err), Payload: nil}
+	}
+	defer iterator.Close()
+	changesList := make([]lib.AdminChange, 0)
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Iterator error: %s", err), Payload: nil}
+		}
+		var change lib.AdminChange
+		err = json.Unmarshal(kv.Value, &change)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		}
+		changesList = append(changesList, change)
+	}
+	// 序列化变更记录列表
+	changesListJSON, err := json.Marshal(changesList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: changesListJSON}
+}
+
+// 保存提案；当前管理员中批准的人数达到法定人数时执行提案并记录变更
+func approveAdminProposal(stub shim.ChaincodeStubInterface, supplier *lib.Supplier, proposal *lib.AdminProposal) pb.Response {
+	// 只统计仍为管理员的批准者
+	approved := 0
+	for _, admin := range proposal.Approvals {
+		if utils.ContainsName(supplier.Admins, admin) {
+			approved++
+		}
+	}
+
+	message := "Approve successful"
+	if approved >= supplier.Quorum {
+		newSupplier, err := applyAdminProposal(supplier, proposal)
+		if err != nil {
+			return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid proposal: %s", err), Payload: nil}
+		}
+		proposal.State = lib.ProposalExecuted
+
+		// 记录变更
+		err = appendAdminChange(stub, newSupplier, proposal.Action, proposal.ProposalID, proposal.Approvals)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Record admin change error: %s", err), Payload: nil}
+		}
+
+		// 更新供应商
+		err = putSupplier(stub, newSupplier)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier error: %s", err), Payload: nil}
+		}
+		message = "Change executed"
+	}
+
+	// 保存提案，每个提案单独保存，并发的提案互不冲突
+	err := putAdminProposal(stub, proposal)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put admin proposal error: %s", err), Payload: nil}
+	}
+
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+	return pb.Response{Status: 200, Message: message, Payload: proposalJSON}
+}
+
+// 在供应商的副本上执行提案，返回执行后的供应商，不修改原对象
+func applyAdminProposal(supplier *lib.Supplier, proposal *lib.AdminProposal) (*lib.Supplier, error) {
+	newSupplier := *supplier
+	newSupplier.Admins = append([]string(nil), supplier.Admins...)
+
+	switch proposal.Action {
+	case lib.AdminAdd:
+		if utils.ContainsName(newSupplier.Admins, proposal.Admin) {
+			return nil, fmt.Errorf("%s is already an admin", proposal.Admin)
+		}
+		newSupplier.Admins = append(newSupplier.Admins, proposal.Admin)
+	case lib.AdminRotate:
+		if !utils.ContainsName(newSupplier.Admins, proposal.Admin) {
+			return nil, fmt.Errorf("%s is not an admin", proposal.Admin)
+		}
+		if utils.ContainsName(newSupplier.Admins, proposal.NewAdmin) {
+			return nil, fmt.Errorf("%s is already an admin", proposal.NewAdmin)
+		}
+		for i, admin := range newSupplier.Admins {
+			if admin == proposal.Admin {
+				newSupplier.Admins[i] = proposal.NewAdmin
+			}
+		}
+	case lib.AdminRevoke:
+		if !utils.ContainsName(newSupplier.Admins, proposal.Admin) {
+			return nil, fmt.Errorf("%s is not an admin", proposal.Admin)
+		}
+		if len(newSupplier.Admins)-1 < newSupplier.Quorum {
+			return nil, fmt.Errorf("revoking would leave fewer admins than the quorum %d", newSupplier.Quorum)
+		}
+		admins := newSupplier.Admins[:0]
+		for _, admin := range newSupplier.Admins {
+			if admin != proposal.Admin {
+				admins = append(admins, admin)
+			}
+		}
+		newSupplier.Admins = admins
+	case lib.AdminSetQuorum:
+		if proposal.Quorum < 1 || proposal.Quorum > len(newSupplier.Admins) {
+			return nil, fmt.Errorf("the quorum must be between 1 and %d", len(newSupplier.Admins))
+		}
+		newSupplier.Quorum = proposal.Quorum
+	default:
+		return nil, fmt.Errorf("unknown action %s", proposal.Action)
+	}
+	return &newSupplier, nil
+}
+
+// 读取账本，获取供应商指定 ID 的管理员变更提案，不存在时返回 nil
+func getAdminProposal(stub shim.ChaincodeStubInterface, supplierName string, proposalID string) (*lib.AdminProposal, error) {
+	key, err := utils.ConstructAdminProposalKey(stub, supplierName, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	proposal := new(lib.AdminProposal)
+	found, err := getStateJSON(stub, key, proposal)
+	if err != nil || !found {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// 将管理员变更提案写入账本
+func putAdminProposal(stub shim.ChaincodeStubInterface, proposal *lib.AdminProposal) error {
+	key, err := utils.ConstructAdminProposalKey(stub, proposal.SupplierName, proposal.ProposalID)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, proposal)
+}
+
+// 提案是否已超过批准期限，旧版本没有期限的提案不过期
+func proposalExpired(proposal *lib.AdminProposal, now time.Time) bool {
+	return !proposal.Deadline.IsZero() && now.After(proposal.Deadline)
+}
+
+// 验证提案仍可批准：尚未执行或撤回，且未超过批准期限，失败时返回错误响应
+func checkPendingProposal(stub shim.ChaincodeStubInterface, proposal *lib.AdminProposal) *pb.Response {
+	if proposal.State != lib.ProposalPending {
+		return &pb.Response{Status: 400, Message: fmt.Sprintf("The proposal has already been %s", strings.ToLower(proposal.State)), Payload: nil}
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction timestamp error: %s", err), Payload: nil}
+	}
+	if proposalExpired(proposal, now) {
+		return &pb.Response{Status: 400, Message: "The proposal has expired", Payload: nil}
+	}
+	return nil
+}
+
+// 为供应商分配下一个变更记录序号，并将管理员变更记录写入账本，调用者需将供应商写入账本
+func appendAdminChange(stub shim.ChaincodeStubInterface, supplier *lib.Supplier, action string, proposalID string, approvals []string) error {
+	changeTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	supplier.ChangeSeq++
+	key, err := utils.ConstructAdminChangeKey(stub, supplier.SupplierName, supplier.ChangeSeq)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, lib.AdminChange{
+		Sequence:     supplier.ChangeSeq,
+		TxID:         stub.GetTxID(),
+		Time:         changeTime,
+		SupplierName: supplier.SupplierName,
+		ProposalID:   proposalID,
+		Action:       action,
+		Approvals:    approvals,
+		Admins:       supplier.Admins,
+		Quorum:       supplier.Quorum,
+	})
+}
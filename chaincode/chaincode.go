@@ -8,27 +8,38 @@ import (
 	"github.com/vendor-manage-inventory/chaincode/lib"
 	"github.com/vendor-manage-inventory/chaincode/utils"
 	"strconv"
+	"time"
 )
 
 type MedicalSystem struct {
 }
 
 func (t *MedicalSystem) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	// 初始化供应商
-	supplierBytes := []byte("supplierAdmin")
-	err := stub.PutState(lib.KeyOfSupplier, supplierBytes)
+	// 实例化或升级链码需满足通道的背书策略（默认为组织管理员），调用者记录为通道管理员，负责注册供应商
+	err := addChannelAdmin(stub)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Record channel admin error: %s", err), Payload: nil}
 	}
-	// 初始化补货方案map
-	schemesMap := make(map[string]lib.ReplenishmentScheme)
-	schemesMapJSON,err := json.Marshal(schemesMap)
+
+	// 从旧版本升级时保留原有数据，由 migrateLedger 迁移
+	legacy, err := hasLegacyLayout(stub)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check ledger layout error: %s", err), Payload: nil}
+	}
+	if legacy {
+		return pb.Response{Status: 200, Message: "Initialize successful, the ledger needs to be migrated", Payload: nil}
 	}
-	err = stub.PutState(lib.KeyOfSchemesMap,schemesMapJSON)
+
+	// 记录账本布局版本
+	version, err := getLedgerVersion(stub)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get ledger version error: %s", err), Payload: nil}
+	}
+	if version == 0 {
+		err = putConfig(stub, lib.ConfigLedgerVersion, lib.LedgerVersion)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Put ledger version error: %s", err), Payload: nil}
+		}
 	}
 
 	return pb.Response{Status: 200, Message: "Initialize successful", Payload: nil}
@@ -36,6 +47,24 @@ func (t *MedicalSystem) Init(stub shim.ChaincodeStubInterface) pb.Response {
 
 func (t *MedicalSystem) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	function, args := stub.GetFunctionAndParameters()
+	// 账本数据布局迁移完成前，只允许执行迁移
+	if function == "migrateLedger" {
+		return t.migrateLedger(stub, args)
+	}
+	version, err := getLedgerVersion(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get ledger version error: %s", err), Payload: nil}
+	}
+	if version < lib.LedgerVersion {
+		return pb.Response{Status: 400, Message: "The ledger needs to be migrated, invoke migrateLedger first", Payload: nil}
+	}
+
+	// 根据证书中的角色属性与权限表验证调用者能否调用该函数
+	err = checkPermission(stub, function)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
 	if function == "retailerRegistration" {
 		// 零售商注册账号
 		return t.retailerRegistration(stub, args)
@@ -54,89 +83,200 @@ func (t *MedicalSystem) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	} else if function == "supplierViewSchemes" {
 		// 供货商查看零售商们补货方案
 		return t.supplierViewSchemes(stub, args)
+	} else if function == "supplierRegistration" {
+		// 供应商注册
+		return t.supplierRegistration(stub, args)
+	} else if function == "supplierList" {
+		// 查看供应商列表
+		return t.supplierList(stub, args)
+	} else if function == "supplierDeactivate" {
+		// 供应商停用
+		return t.supplierDeactivate(stub, args)
+	} else if function == "supplierProposeAdminChange" {
+		// 供应商管理员发起管理员变更提案
+		return t.supplierProposeAdminChange(stub, args)
+	} else if function == "supplierApproveAdminChange" {
+		// 供应商管理员批准管理员变更提案
+		return t.supplierApproveAdminChange(stub, args)
+	} else if function == "supplierViewAdminProposals" {
+		// 查看供应商管理员变更提案
+		return t.supplierViewAdminProposals(stub, args)
+	} else if function == "supplierViewAdminChanges" {
+		// 查看供应商管理员变更记录
+		return t.supplierViewAdminChanges(stub, args)
+	} else if function == "supplierCancelAdminChange" {
+		// 供应商管理员撤回自己发起的管理员变更提案
+		return t.supplierCancelAdminChange(stub, args)
+	} else if function == "retailerResubmit" {
+		// 被否决的零售商重新提交注册信息
+		return t.retailerResubmit(stub, args)
+	} else if function == "retailerAddMember" {
+		// 零售商注册身份添加可以代表该零售商操作的成员
+		return t.retailerAddMember(stub, args)
+	} else if function == "retailerRemoveMember" {
+		// 零售商注册身份移除成员
+		return t.retailerRemoveMember(stub, args)
+	} else if function == "retailerUpdateProfile" {
+		// 零售商提出修改补货参数
+		return t.retailerUpdateProfile(stub, args)
+	} else if function == "supplierAuditProfile" {
+		// 供货商通过与拒绝零售商的补货参数修改
+		return t.supplierAuditProfile(stub, args)
+	} else if function == "setPermission" {
+		// 通道管理员修改函数的角色权限
+		return t.setPermission(stub, args)
+	} else if function == "viewPermissions" {
+		// 查看权限表
+		return t.viewPermissions(stub, args)
+	} else if function == "viewScheme" {
+		// 查看零售商的最新或指定补货方案
+		return t.viewScheme(stub, args)
+	} else if function == "viewSchemeHistory" {
+		// 查看零售商的全部补货方案
+		return t.viewSchemeHistory(stub, args)
+	} else if function == "getRetailerHistory" {
+		// 查看零售商记录的历史版本
+		return t.getRetailerHistory(stub, args)
+	} else if function == "getSchemeHistory" {
+		// 查看补货方案的历史版本
+		return t.getSchemeHistory(stub, args)
+	} else if function == "supplierSetConfig" {
+		// 供应商管理员修改补货计算的默认配置
+		return t.supplierSetConfig(stub, args)
+	} else if function == "supplierSetRetailerConfig" {
+		// 供应商管理员设置零售商对补货配置的覆盖
+		return t.supplierSetRetailerConfig(stub, args)
+	} else if function == "supplierViewConfig" {
+		// 供应商管理员查看补货配置
+		return t.supplierViewConfig(stub, args)
+	} else if function == "supplierConfigHistory" {
+		// 供应商管理员查看补货配置的修改记录
+		return t.supplierConfigHistory(stub, args)
+	} else if function == "supplierSetPriceBreaks" {
+		// 供应商管理员设置零售商的价格折扣表
+		return t.supplierSetPriceBreaks(stub, args)
+	} else if function == "viewPriceBreaks" {
+		// 查看零售商的价格折扣表
+		return t.viewPriceBreaks(stub, args)
+	} else if function == "supplierUpdateStock" {
+		// 供应商更新库存量
+		return t.supplierUpdateStock(stub, args)
+	} else if function == "supplierAllocateStock" {
+		// 供应商将库存配给待回应的补货方案
+		return t.supplierAllocateStock(stub, args)
+	} else if function == "supplierOverdueReports" {
+		// 供应商查询逾期未上报库存的零售商
+		return t.supplierOverdueReports(stub, args)
+	} else if function == "supplierShipOrder" {
+		// 供应商发货
+		return t.supplierShipOrder(stub, args)
+	} else if function == "supplierDeliverOrder" {
+		// 供应商标记采购订单送达
+		return t.supplierDeliverOrder(stub, args)
+	} else if function == "retailerReceiveOrder" {
+		// 零售商收货
+		return t.retailerReceiveOrder(stub, args)
+	} else if function == "viewOrders" {
+		// 查看零售商的采购订单
+		return t.viewOrders(stub, args)
+	} else if function == "retailerCounterScheme" {
+		// 零售商对补货方案还价
+		return t.retailerCounterScheme(stub, args)
+	} else if function == "supplierCounterScheme" {
+		// 供应商对零售商的还价再次还价
+		return t.supplierCounterScheme(stub, args)
+	} else if function == "supplierAcceptCounter" {
+		// 供应商同意零售商的还价
+		return t.supplierAcceptCounter(stub, args)
+	} else if function == "supplierExpiringSchemes" {
+		// 供应商查询即将过期的补货方案
+		return t.supplierExpiringSchemes(stub, args)
+	} else if function == "supplierExpireSchemes" {
+		// 供应商将超过回应期限的补货方案标记为已过期
+		return t.supplierExpireSchemes(stub, args)
+	} else if function == "supplierResolveClaim" {
+		// 供应商确认收货差异索赔或提出异议
+		return t.supplierResolveClaim(stub, args)
+	} else if function == "supplierViewClaims" {
+		// 供应商查看待处理的索赔
+		return t.supplierViewClaims(stub, args)
+	} else if function == "viewClaims" {
+		// 查看零售商的索赔
+		return t.viewClaims(stub, args)
 	}
 
 	return shim.Error("Invalid invoke function name.")
 }
 
 // 零售商注册账号
-// 参数： 零售商名称 订货单价 提前期 初始库存 需求量均值 上传数据的周期 库存商品价值 年利率 固定订货成本 审查周期
+// 参数： 零售商名称 订货单价 提前期 初始库存 需求量均值 上传数据的周期 库存商品价值 年利率 固定订货成本 审查周期 供应商名称列表（以逗号分隔） [需求量标准差 目标服务水平（百分比） [提前期标准差 [最大存储容量（0 表示不限制）]]]
 // 返回： 空
 func (t *MedicalSystem) retailerRegistration(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	// 检查参数个数
-	if len(args) != 10 {
-		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 10", Payload: nil}
+	if len(args) < 11 || len(args) == 12 || len(args) > 15 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 11, 13, 14 or 15", Payload: nil}
 	}
 	// 判断参数合法性（每个参数都不能为空）
-	if args[0] == "" || args[1] == "" || args[2] == "" || args[3] == "" || args[4] == "" || args[5] == "" || args[6] == "" || args[7] == "" || args[8] == "" || args[9] == "" {
+	if args[0] == "" || args[1] == "" || args[2] == "" || args[3] == "" || args[4] == "" || args[5] == "" || args[6] == "" || args[7] == "" || args[8] == "" || args[9] == "" || args[10] == "" {
 		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
 	}
-	// 赋值变量，与函数前参数说明顺序一致。部分参数需转换数据类型（与 Retailer 结构体各属性的数据类型一致）
-	retailerName := args[0] // 零售商名称
-	// 将 string 转换为 float64，下同
-	unitPrice, err := strconv.ParseFloat(args[1], 64) // 订货单价
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
-	}
-	// 将 string 转换为 int，下同
-	leadTime, err := strconv.Atoi(args[2]) // 提前期
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
-	}
-	inventory, err := strconv.Atoi(args[3]) // 库存量
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	for _, arg := range args[11:] {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
 	}
-	averageDemand, err := strconv.Atoi(args[4]) // 需求量均值
+	// 解析参数，创建零售商对象
+	retailer, err := parseRetailerArgs(args)
 	if err != nil {
 		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
 	}
-	updateCycle, err := strconv.Atoi(args[5]) // 上传数据的周期
+	err = checkRetailerProfile(&retailer.RetailerProfile)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid retailer profile: %s", err), Payload: nil}
 	}
-	inventoryValue, err := strconv.ParseFloat(args[6], 64) // 库存商品价值
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	if retailer.Inventory < 0 {
+		return pb.Response{Status: 400, Message: "The inventory cannot be negative", Payload: nil}
 	}
-	annualInterestRate, err := strconv.ParseFloat(args[7], 64) // 年利率
+
+	// 只有零售商组织的成员可以注册，零售商与注册者身份绑定
+	mspID, callerID, err := utils.GetCallerIdentity(stub)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
 	}
-	fixedOrderCost, err := strconv.ParseFloat(args[8], 64) // 固定订货成本
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	if mspID != lib.RetailerMSP {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: the caller is not a member of %s", lib.RetailerMSP), Payload: nil}
 	}
-	reviewCycle, err := strconv.Atoi(args[9]) // 审查周期
+
+	// 零售商名称不能重复，已注册的零售商不会被覆盖
+	existing, err := getRetailer(stub, retailer.RetailerName)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
-	}
-	// 创建零售商对象
-	retailer := lib.Retailer{
-		RetailerName:       retailerName,
-		UnitPrice:          unitPrice,
-		LeadTime:           leadTime,
-		Inventory:          inventory,
-		AverageDemand:      averageDemand,
-		UpdateCycle:        updateCycle,
-		InventoryValue:     inventoryValue,
-		AnnualInterestRate: annualInterestRate,
-		FixedOrderCost:     fixedOrderCost,
-		ReviewCycle:        reviewCycle,
-		State:              lib.ToBeResponded,
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if existing != nil {
+		return pb.Response{Status: 400, Message: "The retailer already exists", Payload: nil}
 	}
-	// 序列化对象
-	retailerJSON, err := json.Marshal(retailer)
+
+	// 绑定供应商，每个供应商都必须已注册且处于启用状态
+	err = checkActiveSuppliers(stub, retailer.Suppliers)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 400, Message: err.Error(), Payload: nil}
 	}
+	retailer.State = lib.ToBeResponded
+	retailer.Owner = callerID
 	// 写入账本
-	err = stub.PutState(retailer.RetailerName, retailerJSON)
+	err = putRetailer(stub, retailer)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
 	}
 
-
+	// 发出零售商注册事件
+	err = emitEvent(stub, lib.EventRetailerRegistered, &lib.Event{
+		RetailerName: retailer.RetailerName,
+		State:        retailer.State,
+		Inventory:    retailer.Inventory,
+	})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
+	}
 
 	return pb.Response{Status: 200, Message: "Register successful", Payload: nil}
 }
@@ -154,17 +294,17 @@ func (t *MedicalSystem) retailerViewScheme(stub shim.ChaincodeStubInterface, arg
 	retailerName := args[0]
 
 	// 读取账本，获取该零售商对象
-	retailerJSON, err := stub.GetState(retailerName)
+	retailer, err := getRetailer(stub, retailerName)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
-	} else if retailerJSON == nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
 		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
 	}
-	retailer := new(lib.Retailer)
-	// 反序列化对象
-	err = json.Unmarshal(retailerJSON, retailer)
+
+	// 验证调用者为该零售商的注册身份或成员
+	err = checkRetailerMember(stub, retailer)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
 	}
 
 	// 验证零售商信息已由供应商审核通过
@@ -172,9 +312,22 @@ func (t *MedicalSystem) retailerViewScheme(stub shim.ChaincodeStubInterface, arg
 		return pb.Response{Status: 400, Message: "The retailer failed the audit", Payload: nil}
 	}
 
-	replenishmentSchemeJSON, err := stub.GetState(utils.ConstructSchemeKey(retailerName))
+	// 读取账本，获取该零售商的补货方案对象
+	replenishmentScheme, err := getLatestScheme(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get scheme error: %s", err), Payload: nil}
+	} else if replenishmentScheme == nil {
+		return pb.Response{Status: 400, Message: "The scheme does not exist", Payload: nil}
+	}
+	// 超过回应期限的补货方案显示为已过期，不写入账本
+	err = showExpired(stub, replenishmentScheme)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
+	}
+	// 序列化对象
+	replenishmentSchemeJSON, err := json.Marshal(replenishmentScheme)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
 	}
 
 	return pb.Response{Status: 200, Message: "View successful", Payload: replenishmentSchemeJSON}
@@ -182,7 +335,7 @@ func (t *MedicalSystem) retailerViewScheme(stub shim.ChaincodeStubInterface, arg
 
 // 零售商回应补货方案
 // 参数： 零售商名称 回应（0或1）
-// 返回： 空 或 补货前与补货后库存量
+// 返回： 空 或 库存量、未收货数量与创建的采购订单
 func (t *MedicalSystem) retailerResponseScheme(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	// 检查参数个数
 	if len(args) != 2 {
@@ -200,17 +353,17 @@ func (t *MedicalSystem) retailerResponseScheme(stub shim.ChaincodeStubInterface,
 	result := args[1]
 
 	// 读取账本，获取该零售商对象
-	retailerJSON, err := stub.GetState(retailerName)
+	retailer, err := getRetailer(stub, retailerName)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
-	} else if retailerJSON == nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
 		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
 	}
-	retailer := new(lib.Retailer)
-	// 反序列化对象
-	err = json.Unmarshal(retailerJSON, retailer)
+
+	// 验证调用者为该零售商的注册身份或成员
+	err = checkRetailerMember(stub, retailer)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
 	}
 
 	// 验证零售商信息已由供应商审核通过
@@ -219,116 +372,67 @@ func (t *MedicalSystem) retailerResponseScheme(stub shim.ChaincodeStubInterface,
 	}
 
 	// 读取账本，获取该零售商的补货方案对象
-	replenishmentSchemeJSON, err := stub.GetState(utils.ConstructSchemeKey(retailerName))
+	replenishmentScheme, err := getLatestScheme(stub, retailer)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get scheme error: %s", err), Payload: nil}
+	} else if replenishmentScheme == nil {
+		return pb.Response{Status: 400, Message: "The scheme does not exist", Payload: nil}
 	}
-	var replenishmentScheme lib.ReplenishmentScheme
-	// 反序列化对象
-	err = json.Unmarshal(replenishmentSchemeJSON, &replenishmentScheme)
+	// 超过回应期限的补货方案不能再回应，过期状态由生成新补货方案或 supplierExpireSchemes 写入账本
+	expired, err := schemeExpired(stub, replenishmentScheme)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
 	}
-
-	// 更新供应商的补货方案Map
-	// 获取补货方案map
-	schemesMapJSON,err := stub.GetState(lib.KeyOfSchemesMap)
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
+	if expired {
+		return pb.Response{Status: 400, Message: "The scheme has expired", Payload: nil}
+	}
+	// 只能回应待回应的补货方案，已回应的方案作为历史记录保留；协商中的补货方案需等待供应商回应还价
+	target := lib.Pass
+	if result == "0" {
+		target = lib.Veto
 	}
-	// 反序列化补货方案map
-	schemesMap := make(map[string]lib.ReplenishmentScheme)
-	err = json.Unmarshal(schemesMapJSON, &schemesMap)
+	err = checkSchemeTransition(replenishmentScheme, target)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Illegal scheme transition: %s", err), Payload: nil}
+	}
+	if lastCounterParty(replenishmentScheme) == lib.PartyRetailer {
+		return pb.Response{Status: 400, Message: "The scheme is waiting for the supplier to respond to the counter-proposal", Payload: nil}
 	}
 
 	// 判断回应
 	// 如果为0，即为不同意
 	if result == "0" {
+		// 释放配给该补货方案的供应商库存
+		err = releaseAllocation(stub, replenishmentScheme)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Release allocation error: %s", err), Payload: nil}
+		}
 		// 修改补货方案的回应结果为 不同意
-		replenishmentScheme.ResponseResults = lib.Veto
-		// 序列化对象
-		replenishmentSchemeJSON, err = json.Marshal(replenishmentScheme)
+		err = transitionScheme(replenishmentScheme, lib.Veto)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+			return pb.Response{Status: 400, Message: fmt.Sprintf("Illegal scheme transition: %s", err), Payload: nil}
 		}
 		// 写入账本
-		err = stub.PutState(utils.ConstructSchemeKey(retailerName), replenishmentSchemeJSON)
+		err = putScheme(stub, replenishmentScheme)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Put scheme error: %s", err), Payload: nil}
 		}
 
-		// 更新当前零售商补货方案到map中
-		schemesMap[retailerName] = replenishmentScheme
-		// 序列化
-		schemesMapJSON,err = json.Marshal(schemesMap)
+		// 发出补货方案回应事件
+		err = emitSchemeResponded(stub, retailer, replenishmentScheme, nil)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
-		}
-		// 写入账本
-		err = stub.PutState(lib.KeyOfSchemesMap,schemesMapJSON)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
 		}
 
 		return pb.Response{Status: 200, Message: "Veto successful", Payload: nil}
 	} else { // 如果为1，即为同意
-
-		// 获取旧的库存量
-		oldInventory := retailer.Inventory
-		// 修改库存量
-		retailer.Inventory += replenishmentScheme.ReorderQuantity
-
-		// 修改补货方案的回应结果为 同意
-		replenishmentScheme.ResponseResults = lib.Pass
-		// 序列化对象
-		replenishmentSchemeJSON, err = json.Marshal(replenishmentScheme)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
-		}
-		// 写入账本
-		err = stub.PutState(utils.ConstructSchemeKey(retailerName), replenishmentSchemeJSON)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
-		}
-
-		// 序列化对象
-		retailerJSON, err = json.Marshal(retailer)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
-		}
-		// 写入账本
-		err = stub.PutState(retailerName, retailerJSON)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
-		}
-
-		// 更新当前零售商补货方案到map中
-		schemesMap[retailerName] = replenishmentScheme
-		// 序列化
-		schemesMapJSON,err = json.Marshal(schemesMap)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
-		}
-		// 写入账本
-		err = stub.PutState(lib.KeyOfSchemesMap,schemesMapJSON)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
-		}
-		
-		// 使用匿名结构体存储要返回的内容
-		res := struct {
-			OldInventory int // 补货前库存量
-			NewInventory int // 补货后库存量
-		}{oldInventory, retailer.Inventory}
-		// 序列化返回值
-		resJSON, err := json.Marshal(res)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+		// 协商中的补货方案按供应商最后提出的条件同意，否则按补货数量；经过库存配给的不超过配给数量
+		quantity, deliveryDate := replenishmentScheme.ReorderQuantity, ""
+		if n := len(replenishmentScheme.Negotiation); n > 0 {
+			quantity = replenishmentScheme.Negotiation[n-1].Quantity
+			deliveryDate = replenishmentScheme.Negotiation[n-1].DeliveryDate
 		}
-		
-		return pb.Response{Status: 200, Message: "Pass successful", Payload: resJSON}
+		return acceptScheme(stub, retailer, replenishmentScheme, quantity, deliveryDate)
 	}
 }
 
@@ -351,19 +455,22 @@ func (t *MedicalSystem) retailerUpdateInventory(stub shim.ChaincodeStubInterface
 	if err != nil {
 		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
 	}
+	if newInventory < 0 {
+		return pb.Response{Status: 400, Message: "The inventory cannot be negative", Payload: nil}
+	}
 
 	// 读取账本，获取该零售商对象
-	retailerJSON, err := stub.GetState(retailerName)
+	retailer, err := getRetailer(stub, retailerName)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
-	} else if retailerJSON == nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
 		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
 	}
-	retailer := new(lib.Retailer)
-	// 反序列化对象
-	err = json.Unmarshal(retailerJSON, retailer)
+
+	// 验证调用者为该零售商的注册身份或成员
+	err = checkRetailerMember(stub, retailer)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
 	}
 
 	// 验证零售商信息已由供应商审核通过
@@ -371,83 +478,72 @@ func (t *MedicalSystem) retailerUpdateInventory(stub shim.ChaincodeStubInterface
 		return pb.Response{Status: 400, Message: "The retailer failed the audit", Payload: nil}
 	}
 
-	// 更新库存量
-	retailer.Inventory = newInventory
-
-	// 序列化对象
-	retailerJSON, err = json.Marshal(retailer)
+	// 按上报周期检查本次库存上报，提前上报按配置拒绝或标记，逾期上报接受并标记
+	config, err := getEffectiveConfig(stub, retailer.Supplier, retailer.RetailerName)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
 	}
-	// 写入账本
-	err = stub.PutState(retailerName, retailerJSON)
+	now, err := utils.GetTxTime(stub)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
 	}
-
-	// 计算订购点s
-	// 订购点s = 提前期 x 需求量均值 + 残值
-	s := retailer.LeadTime*retailer.AverageDemand + lib.ResidualValue
-	// 如果库存量低于s时，计算补货数量
-	var reorderQuantity int
-	if retailer.Inventory < s {
-		// 补货数量 = (T + 提前期) * 需求量 + 残值 - 当前报告的库存量
-		reorderQuantity = (retailer.ReviewCycle+retailer.LeadTime)*retailer.AverageDemand + lib.ResidualValue - retailer.Inventory
-	} else {
-		reorderQuantity = 0
+	reportStatus := checkReportCycle(retailer, config, now)
+	if reportStatus == lib.ReportEarly && config.ReportEnforcement == lib.ReportReject {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The inventory report is earlier than the update cycle, the next report is accepted after %s", reportEarliestTime(retailer, config).Format(time.RFC3339)), Payload: nil}
 	}
-	// 创建补货方案对象
-	replenishmentScheme := lib.ReplenishmentScheme{
-		RetailerName:    retailerName,
-		ReorderQuantity: reorderQuantity,
-		UnitPrice:       retailer.UnitPrice,
-		ResponseResults: lib.ToBeResponded,
+	// 记录上次上报以来的需求量，并更新需求量的估计
+	recordDemand(retailer, config, newInventory, now)
+	retailer.ReceivedSinceReport = 0
+	retailer.LastReportTime = now
+	retailer.ReportStatus = reportStatus
+	if reportStatus == lib.ReportEarly {
+		retailer.EarlyReports++
+	} else if reportStatus == lib.ReportLate {
+		retailer.LateReports++
 	}
-	// 序列化对象
-	replenishmentSchemeJSON, err := json.Marshal(replenishmentScheme)
+
+	// 更新库存量
+	retailer.Inventory = newInventory
+
+	// 根据新的库存量生成补货方案，分配新的序号，之前的补货方案保留在账本中
+	replenishmentScheme, err := generateScheme(stub, retailer, retailer.Supplier)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Create scheme error: %s", err), Payload: nil}
 	}
 	// 写入账本
-	err = stub.PutState(utils.ConstructSchemeKey(retailerName), replenishmentSchemeJSON)
+	err = putScheme(stub, replenishmentScheme)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put scheme error: %s", err), Payload: nil}
 	}
 
-	// 更新供应商的补货方案Map
-	// 获取补货方案map
-	schemesMapJSON,err := stub.GetState(lib.KeyOfSchemesMap)
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
-	}
-	// 反序列化补货方案map
-	schemesMap := make(map[string]lib.ReplenishmentScheme)
-	err = json.Unmarshal(schemesMapJSON, &schemesMap)
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
-	}
-	// 添加当前零售商补货方案到map中
-	schemesMap[retailerName] = replenishmentScheme
-	// 序列化
-	schemesMapJSON,err = json.Marshal(schemesMap)
+	// 写入账本
+	err = putRetailer(stub, retailer)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
 	}
-	// 写入账本
-	err = stub.PutState(lib.KeyOfSchemesMap,schemesMapJSON)
+
+	// 发出库存上报事件，附带新生成的补货方案
+	err = emitEvent(stub, lib.EventInventoryReported, &lib.Event{
+		RetailerName: retailer.RetailerName,
+		SupplierName: retailer.Supplier,
+		State:        retailer.State,
+		Inventory:    retailer.Inventory,
+		Scheme:       replenishmentScheme,
+		ReportStatus: retailer.ReportStatus,
+	})
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
 	}
 
 	return pb.Response{Status: 200, Message: "Update successful", Payload: nil}
 }
 
-// 供货商通过与拒绝零售商注册
-// 参数： 供应商名称 零售商名称 回应（0或1）
+// 供货商通过与拒绝零售商注册，通过时可约定补货策略
+// 参数： 供应商名称 零售商名称 回应（0或1） [补货策略名称 [策略参数JSON，如 {"s":20,"S":60}]]
 // 返回： 空
 func (t *MedicalSystem) supplierAuditRegistration(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 3 {
-		errMes := "Incorrect number of arguments. Expecting 3"
+	if len(args) < 3 || len(args) > 5 {
+		errMes := "Incorrect number of arguments. Expecting 3 to 5"
 		return pb.Response{Status: 400, Message: errMes, Payload: []byte(errMes)}
 	}
 	if args[0] == "" || args[1] == "" || args[2] == "" {
@@ -461,99 +557,91 @@ func (t *MedicalSystem) supplierAuditRegistration(stub shim.ChaincodeStubInterfa
 	result := args[2]
 
 	// 读取账本，获取供应商
-	supplierBytes, err := stub.GetState("supplier")
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	// 验证调用者为该供应商的管理员
+	err = checkSupplierAdmin(stub, supplier)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
 	}
-	// 验证供应商名称是否正确
-	if string(supplierBytes) != supplierName {
-		return pb.Response{Status: 400, Message: "Incorrect supplier name", Payload: nil}
+	if supplier.State != lib.Active {
+		return pb.Response{Status: 400, Message: "The supplier has been deactivated", Payload: nil}
 	}
 
 	// 读取账本，获取该零售商对象
-	retailerJSON, err := stub.GetState(retailerName)
+	retailer, err := getRetailer(stub, retailerName)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
-	} else if retailerJSON == nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
 		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
 	}
-	retailer := new(lib.Retailer)
-	// 反序列化对象
-	err = json.Unmarshal(retailerJSON, retailer)
-	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+
+	// 只能审核绑定了该供应商的零售商
+	if !utils.ContainsName(retailer.Suppliers, supplierName) {
+		return pb.Response{Status: 403, Message: "Permission denied: the retailer is not bound to this supplier", Payload: nil}
+	}
+	// 只能审核待审核的注册，已由其他供应商负责补货的零售商不能再审核
+	if retailer.Supplier != "" && retailer.Supplier != supplierName {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The retailer is already served by supplier %s", retailer.Supplier), Payload: nil}
+	}
+	if retailer.State != lib.ToBeResponded {
+		return pb.Response{Status: 400, Message: "The registration is not waiting for audit", Payload: nil}
 	}
 
-	// 根据回应更改零售商状态
+	// 根据回应更改零售商状态，通过时生成补货方案
+	var replenishmentScheme *lib.ReplenishmentScheme
 	if result == "0" {
 		retailer.State = lib.Veto
 	} else if result == "1" {
-		retailer.State = lib.Pass
-
-		// 生成该零售商的补货方案
-		// 计算订购点 s ：  订购点s = 提前期 x 需求量均值 + 残值
-		s := retailer.LeadTime*retailer.AverageDemand + lib.ResidualValue
-		var reorderQuantity int
-		// 如果库存量低于s时，计算补货数量
-		if retailer.Inventory < s {
-			// 补货数量 = (T + 提前期) * 需求量 + 残值 - 当前报告的库存量
-			reorderQuantity = (retailer.ReviewCycle +retailer.LeadTime)*retailer.AverageDemand + lib.ResidualValue - retailer.Inventory
-		} else {
-			reorderQuantity = 0
-		}
-		// 创建补货方案对象
-		replenishmentScheme := lib.ReplenishmentScheme{
-			RetailerName:    retailerName,
-			ReorderQuantity: reorderQuantity,
-			UnitPrice:       retailer.UnitPrice,
-			ResponseResults: lib.ToBeResponded,
-		}
-		// 序列化对象
-		replenishmentSchemeJSON, err := json.Marshal(replenishmentScheme)
+		// 约定补货策略，未指定时使用默认策略
+		retailer.Policy, err = parsePolicyArgs(stub, supplierName, retailerName, args[3:], lib.PolicySetting{}, &retailer.RetailerProfile)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+			return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid replenishment policy: %s", err), Payload: nil}
 		}
-		// 写入账本
-		err = stub.PutState(utils.ConstructSchemeKey(retailerName), replenishmentSchemeJSON)
-		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
-		}
-
-		// 更新供应商的补货方案Map
-		// 获取补货方案map
-		schemesMapJSON,err := stub.GetState(lib.KeyOfSchemesMap)
+		retailer.State = lib.Pass
+		retailer.Supplier = supplierName
+		err = putSupplierRetailer(stub, supplierName, retailerName)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier retailer error: %s", err), Payload: nil}
 		}
-		// 反序列化补货方案map
-		schemesMap := make(map[string]lib.ReplenishmentScheme)
-		err = json.Unmarshal(schemesMapJSON, &schemesMap)
+		// 注册时提交的库存量视为首次上报，上报周期从审核通过时起算
+		retailer.LastReportTime, err = utils.GetTxTime(stub)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
 		}
-		// 添加当前零售商补货方案到map中
-		schemesMap[retailerName] = replenishmentScheme
-		// 序列化
-		schemesMapJSON,err = json.Marshal(schemesMap)
+
+		// 生成该零售商的补货方案，分配新的序号
+		replenishmentScheme, err = generateScheme(stub, retailer, supplierName)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Create scheme error: %s", err), Payload: nil}
 		}
 		// 写入账本
-		err = stub.PutState(lib.KeyOfSchemesMap,schemesMapJSON)
+		err = putScheme(stub, replenishmentScheme)
 		if err != nil {
-			return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Put scheme error: %s", err), Payload: nil}
 		}
 	}
 
-	// 序列化对象
-	retailerJSON, err = json.Marshal(retailer)
+	// 写入账本
+	err = putRetailer(stub, retailer)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
 	}
-	// 写入账本
-	err = stub.PutState(retailerName, retailerJSON)
+
+	// 发出零售商审核事件
+	err = emitEvent(stub, lib.EventRetailerAudited, &lib.Event{
+		RetailerName: retailer.RetailerName,
+		SupplierName: supplierName,
+		State:        retailer.State,
+		Inventory:    retailer.Inventory,
+		Scheme:       replenishmentScheme,
+	})
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("PutState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
 	}
 
 	return pb.Response{Status: 200, Message: "Audit successful", Payload: nil}
@@ -571,26 +659,36 @@ func (t *MedicalSystem) supplierViewSchemes(stub shim.ChaincodeStubInterface, ar
 	if args[0] == "" {
 		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
 	}
-	
-	// 获取补货方案map
-	schemesMapJSON,err := stub.GetState(lib.KeyOfSchemesMap)
+	supplierName := args[0]
+
+	// 读取账本，获取供应商
+	supplier, err := getSupplier(stub, supplierName)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("GetState error: %s", err), Payload: nil}
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
 	}
-	// 反序列化补货方案map
-	schemesMap := make(map[string]lib.ReplenishmentScheme)
-	err = json.Unmarshal(schemesMapJSON, &schemesMap)
+	// 验证调用者为该供应商的管理员
+	err = checkSupplierAdmin(stub, supplier)
 	if err != nil {
-		return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if supplier.State != lib.Active {
+		return pb.Response{Status: 400, Message: "The supplier has been deactivated", Payload: nil}
 	}
-	// 声明补货方案列表
-	var schemesList []lib.ReplenishmentScheme
-	// 遍历补货方案map，将所有补货方案添加到补货方案列表中
-	for _, scheme := range schemesMap {
-		schemesList = append(schemesList, scheme)
+
+	// 获取该供应商负责的各零售商最新的补货方案
+	supplierSchemes, err := listLatestSchemes(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List schemes error: %s", err), Payload: nil}
+	}
+	// 超过回应期限的补货方案显示为已过期，不写入账本
+	err = showExpiredSchemes(stub, supplierSchemes)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
 	}
 	// 序列化补货方案列表
-	schemesListJSON,err := json.Marshal(schemesList)
+	schemesListJSON, err := json.Marshal(supplierSchemes)
 	if err != nil {
 		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
 	}
@@ -598,9 +696,139 @@ func (t *MedicalSystem) supplierViewSchemes(stub shim.ChaincodeStubInterface, ar
 	return pb.Response{Status: 200, Message: "View successful", Payload: schemesListJSON}
 }
 
+// 验证调用者为注册该零售商的身份
+func checkRetailerOwner(stub shim.ChaincodeStubInterface, retailer *lib.Retailer) error {
+	mspID, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	if mspID != lib.RetailerMSP {
+		return fmt.Errorf("the caller is not a member of %s", lib.RetailerMSP)
+	}
+	if callerID == retailer.Owner {
+		return nil
+	}
+	return fmt.Errorf("the caller is not the owner of retailer %s", retailer.RetailerName)
+}
+
+// 验证调用者为注册该零售商的身份，或为注册身份加入的成员（如仓库、采购、审计人员各自的证书）
+// 成员能调用哪些函数仍由证书的 role 属性与权限表决定
+func checkRetailerMember(stub shim.ChaincodeStubInterface, retailer *lib.Retailer) error {
+	mspID, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	if mspID != lib.RetailerMSP {
+		return fmt.Errorf("the caller is not a member of %s", lib.RetailerMSP)
+	}
+	if callerID == retailer.Owner || utils.ContainsName(retailer.Members, callerID) {
+		return nil
+	}
+	return fmt.Errorf("the caller is not a member of retailer %s", retailer.RetailerName)
+}
+
 func main() {
 	err := shim.Start(new(MedicalSystem))
 	if err != nil {
 		fmt.Printf("Error starting Simple chaincode: %s", err)
 	}
 }
+
+// 发出补货方案回应事件，同意时附带创建的采购订单
+func emitSchemeResponded(stub shim.ChaincodeStubInterface, retailer *lib.Retailer, scheme *lib.ReplenishmentScheme, order *lib.PurchaseOrder) error {
+	return emitEvent(stub, lib.EventSchemeResponded, &lib.Event{
+		RetailerName: retailer.RetailerName,
+		SupplierName: scheme.SupplierName,
+		State:        scheme.ResponseResults,
+		Inventory:    retailer.Inventory,
+		Scheme:       scheme,
+		Order:        order,
+	})
+}
+
+// 同意补货方案：按约定的补货数量从供应商库存中扣减（先释放配给占用的库存），创建采购订单并写入账本
+func acceptScheme(stub shim.ChaincodeStubInterface, retailer *lib.Retailer, scheme *lib.ReplenishmentScheme, quantity int, deliveryDate string) pb.Response {
+	// 修改补货方案的回应结果为 同意
+	err := transitionScheme(scheme, lib.Pass)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Illegal scheme transition: %s", err), Payload: nil}
+	}
+	supplier, err := getSupplier(stub, scheme.SupplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	if scheme.Allocated {
+		supplier.Reserved -= scheme.AllocatedQuantity
+		if supplier.Reserved < 0 {
+			supplier.Reserved = 0
+		}
+		// 经过库存配给的补货方案，约定的补货数量不超过配给数量
+		if quantity > scheme.AllocatedQuantity {
+			quantity = scheme.AllocatedQuantity
+		}
+	}
+	// 约定的补货数量不能使库存水平超过存储容量
+	config, err := getEffectiveConfig(stub, scheme.SupplierName, retailer.RetailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+	}
+	quantity = capQuantity(quantity, retailer.StorageCapacity, retailer.Inventory+retailer.OnOrder, config.RoundingMultiple)
+	if quantity > supplier.Stock-supplier.Reserved {
+		return pb.Response{Status: 400, Message: "Insufficient supplier stock", Payload: nil}
+	}
+	supplier.Stock -= quantity
+	err = putSupplier(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier error: %s", err), Payload: nil}
+	}
+
+	// 记录约定的条件
+	scheme.AgreedQuantity = quantity
+	scheme.DeliveryDate = deliveryDate
+
+	// 创建采购订单，收货后才计入库存量，补货数量为 0 时无需订货；补货方案按约定的补货数量重新定价
+	var order *lib.PurchaseOrder
+	scheme.TotalPrice = 0
+	if quantity > 0 {
+		order, err = createOrder(stub, retailer, scheme, quantity)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Create order error: %s", err), Payload: nil}
+		}
+		scheme.UnitPrice = order.UnitPrice
+		scheme.TotalPrice = order.TotalPrice
+	}
+
+	// 写入账本
+	err = putScheme(stub, scheme)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put scheme error: %s", err), Payload: nil}
+	}
+
+	// 写入账本
+	err = putRetailer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
+	}
+
+	// 发出补货方案回应事件
+	err = emitSchemeResponded(stub, retailer, scheme, order)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
+	}
+
+	// 使用匿名结构体存储要返回的内容
+	res := struct {
+		Inventory int                // 库存量
+		OnOrder   int                // 未收货数量
+		Order     *lib.PurchaseOrder // 创建的采购订单
+	}{retailer.Inventory, retailer.OnOrder, order}
+	// 序列化返回值
+	resJSON, err := json.Marshal(res)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Pass successful", Payload: resJSON}
+}
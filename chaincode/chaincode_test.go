@@ -0,0 +1,837 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 证书中保存属性的扩展，与 fabric-ca 签发的证书一致
+var attrsOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// 测试用的客户端身份：自签名证书，CN 为身份名称，role 属性为角色列表
+type identity struct {
+	name    string
+	mspID   string
+	id      string
+	creator []byte
+}
+
+func newIdentity(t *testing.T, name string, mspID string, roles string) *identity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if roles != "" {
+		attrs, err := json.Marshal(map[string]map[string]string{"attrs": {lib.AttrRole: roles}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrsOID, Value: attrs}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与 cid.GetID 相同：x509::主题::签发者 的 base64 编码
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("x509::CN=%s::CN=%s", name, name)))
+	return &identity{name: name, mspID: mspID, id: id, creator: creator}
+}
+
+// 测试用的链码桩：MockStub 不提供交易提交者，由 testStub 按调用者返回证书，并控制交易时间
+// 与 Fabric 一致，状态码不小于 400 的交易不写入账本
+type testStub struct {
+	*shim.MockStub
+	cc      *MedicalSystem
+	args    [][]byte
+	creator []byte
+	now     time.Time
+	txSeq   int
+}
+
+func (s *testStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *testStub) GetStringArgs() []string {
+	args := make([]string, 0, len(s.args))
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+// 以 caller 的身份执行一个交易
+func (s *testStub) call(caller *identity, init bool, args []string) pb.Response {
+	s.txSeq++
+	txID := fmt.Sprintf("tx%d", s.txSeq)
+	s.args = nil
+	for _, arg := range args {
+		s.args = append(s.args, []byte(arg))
+	}
+	s.creator = caller.creator
+	snapshot := make(map[string][]byte, len(s.State))
+	for key, value := range s.State {
+		snapshot[key] = value
+	}
+
+	s.MockTransactionStart(txID)
+	s.TxTimestamp = &timestamp.Timestamp{Seconds: s.now.Unix(), Nanos: int32(s.now.Nanosecond())}
+	var resp pb.Response
+	if init {
+		resp = s.cc.Init(s)
+	} else {
+		resp = s.cc.Invoke(s)
+	}
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		for key := range s.State {
+			if _, ok := snapshot[key]; !ok {
+				s.DelState(key)
+			}
+		}
+		for key, value := range snapshot {
+			s.PutState(key, value)
+		}
+	}
+	s.MockTransactionEnd(txID)
+
+	for len(s.ChaincodeEventsChannel) > 0 {
+		<-s.ChaincodeEventsChannel
+	}
+	return resp
+}
+
+func (s *testStub) invoke(caller *identity, function string, args ...string) pb.Response {
+	return s.call(caller, false, append([]string{function}, args...))
+}
+
+// 执行交易并要求返回指定的状态码
+func (s *testStub) expect(t *testing.T, status int32, caller *identity, function string, args ...string) pb.Response {
+	t.Helper()
+	resp := s.invoke(caller, function, args...)
+	if resp.Status != status {
+		t.Fatalf("%s%v by %s: status %d (%s), want %d", function, args, caller.name, resp.Status, resp.Message, status)
+	}
+	return resp
+}
+
+// 测试用的网络：通道管理员实例化链码，供应商 gongying1 由 planner 管理，零售商 lingshou1 由 pharmacy 注册
+type testNetwork struct {
+	*testStub
+	admin     *identity // 通道管理员
+	planner   *identity // 供应商 gongying1 的管理员
+	pharmacy  *identity // 注册零售商 lingshou1 的身份
+	intruder  *identity // 零售商组织中与 lingshou1 无关的身份
+	outsider  *identity // 供应商组织中不是管理员的身份
+	supplier  string
+	retailer  string
+	startTime time.Time
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	cc := new(MedicalSystem)
+	n := &testNetwork{
+		testStub:  &testStub{MockStub: shim.NewMockStub("vmi", cc), cc: cc},
+		admin:     newIdentity(t, "admin", lib.SupplierMSP, ""),
+		planner:   newIdentity(t, "planner", lib.SupplierMSP, lib.RolePlanner),
+		pharmacy:  newIdentity(t, "pharmacy", lib.RetailerMSP, lib.RoleBuyer+","+lib.RoleWarehouse),
+		intruder:  newIdentity(t, "intruder", lib.RetailerMSP, lib.RoleBuyer+","+lib.RoleWarehouse),
+		outsider:  newIdentity(t, "outsider", lib.SupplierMSP, lib.RolePlanner),
+		supplier:  "gongying1",
+		retailer:  "lingshou1",
+		startTime: time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC),
+	}
+	n.now = n.startTime
+	if resp := n.call(n.admin, true, []string{"init"}); resp.Status != shim.OK {
+		t.Fatalf("Init: status %d (%s)", resp.Status, resp.Message)
+	}
+	n.expect(t, shim.OK, n.admin, "supplierRegistration", n.supplier, n.planner.id)
+	return n
+}
+
+// 注册零售商：单价 5，提前期 2，库存 5，需求量均值 5，上报周期 7，审查周期 3
+// 默认的 sS 策略下订购点为 10、最大库存为 25，审核通过后生成补货数量为 20 的补货方案
+func (n *testNetwork) registerRetailer(t *testing.T, owner *identity, name string) {
+	t.Helper()
+	n.expect(t, shim.OK, owner, "retailerRegistration", name, "5", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier)
+}
+
+// 注册并审核通过零售商 lingshou1，供应商库存 1000
+func (n *testNetwork) passRetailer(t *testing.T) {
+	t.Helper()
+	n.registerRetailer(t, n.pharmacy, n.retailer)
+	n.expect(t, shim.OK, n.planner, "supplierAuditRegistration", n.supplier, n.retailer, "1")
+	n.expect(t, shim.OK, n.planner, "supplierUpdateStock", n.supplier, "1000")
+}
+
+// 读取账本中的零售商
+func (n *testNetwork) getRetailer(t *testing.T, name string) *lib.Retailer {
+	t.Helper()
+	n.MockTransactionStart("read")
+	defer n.MockTransactionEnd("read")
+	retailer, err := getRetailer(n, name)
+	if err != nil || retailer == nil {
+		t.Fatalf("get retailer %s: %v", name, err)
+	}
+	return retailer
+}
+
+// 读取账本中的供应商 gongying1
+func (n *testNetwork) getSupplier(t *testing.T) *lib.Supplier {
+	t.Helper()
+	n.MockTransactionStart("read")
+	defer n.MockTransactionEnd("read")
+	supplier, err := getSupplier(n, n.supplier)
+	if err != nil || supplier == nil {
+		t.Fatalf("get supplier %s: %v", n.supplier, err)
+	}
+	return supplier
+}
+
+// 读取账本中零售商最新的补货方案
+func (n *testNetwork) latestScheme(t *testing.T, name string) *lib.ReplenishmentScheme {
+	t.Helper()
+	retailer := n.getRetailer(t, name)
+	n.MockTransactionStart("read")
+	defer n.MockTransactionEnd("read")
+	scheme, err := getLatestScheme(n, retailer)
+	if err != nil || scheme == nil {
+		t.Fatalf("get latest scheme of %s: %v", name, err)
+	}
+	return scheme
+}
+
+func TestRetailerFunctionsRequireOwner(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+
+	// 零售商组织中的其他身份不能代替该零售商上报库存、查看或回应补货方案
+	n.expect(t, 403, n.intruder, "retailerUpdateInventory", n.retailer, "3")
+	n.expect(t, 403, n.intruder, "retailerViewScheme", n.retailer)
+	n.expect(t, 403, n.intruder, "retailerResponseScheme", n.retailer, "1")
+	// 供应商组织的身份即使有相同的角色也不能代替零售商
+	supplierBuyer := newIdentity(t, "supplier-buyer", lib.SupplierMSP, lib.RoleBuyer)
+	n.expect(t, 403, supplierBuyer, "retailerViewScheme", n.retailer)
+
+	n.expect(t, shim.OK, n.pharmacy, "retailerViewScheme", n.retailer)
+	n.expect(t, shim.OK, n.pharmacy, "retailerUpdateInventory", n.retailer, "3")
+}
+
+func TestSupplierFunctionsRequireAdmin(t *testing.T) {
+	n := newTestNetwork(t)
+	n.registerRetailer(t, n.pharmacy, n.retailer)
+
+	// 名称参数不能代替身份：不是供应商管理员的身份不能审核或查看补货方案
+	n.expect(t, 403, n.outsider, "supplierAuditRegistration", n.supplier, n.retailer, "1")
+	n.expect(t, 403, n.outsider, "supplierViewSchemes", n.supplier)
+	if retailer := n.getRetailer(t, n.retailer); retailer.State != lib.ToBeResponded {
+		t.Fatalf("retailer state = %s after a rejected audit", retailer.State)
+	}
+	n.expect(t, shim.OK, n.planner, "supplierAuditRegistration", n.supplier, n.retailer, "1")
+}
+
+func TestSupplierRegistry(t *testing.T) {
+	n := newTestNetwork(t)
+
+	// 只有实例化链码时记录的通道管理员可以注册供应商
+	n.expect(t, 403, n.planner, "supplierRegistration", "gongying2")
+	n.expect(t, 400, n.admin, "supplierRegistration", n.supplier, n.planner.id)
+	other := newIdentity(t, "other-planner", lib.SupplierMSP, lib.RolePlanner)
+	n.expect(t, shim.OK, n.admin, "supplierRegistration", "gongying2", other.id)
+
+	resp := n.expect(t, shim.OK, n.planner, "supplierList")
+	var suppliers []lib.Supplier
+	if err := json.Unmarshal(resp.Payload, &suppliers); err != nil || len(suppliers) != 2 {
+		t.Fatalf("supplier list = %s, %v", resp.Payload, err)
+	}
+
+	// 供应商只能审核绑定了自己的零售商
+	n.registerRetailer(t, n.pharmacy, n.retailer)
+	n.expect(t, 403, other, "supplierAuditRegistration", "gongying2", n.retailer, "1")
+	// 停用的供应商不能被新注册的零售商绑定
+	n.expect(t, shim.OK, n.planner, "supplierDeactivate", n.supplier)
+	n.expect(t, 400, n.intruder, "retailerRegistration", "lingshou2", "5", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier)
+}
+
+// 解析管理员变更提案列表
+func (n *testNetwork) adminProposals(t *testing.T, caller *identity) []lib.AdminProposal {
+	t.Helper()
+	resp := n.expect(t, shim.OK, caller, "supplierViewAdminProposals", n.supplier)
+	var proposals []lib.AdminProposal
+	if err := json.Unmarshal(resp.Payload, &proposals); err != nil {
+		t.Fatal(err)
+	}
+	return proposals
+}
+
+func TestAdminProposalCancelAndExpiry(t *testing.T) {
+	n := newTestNetwork(t)
+	second := newIdentity(t, "second-planner", lib.SupplierMSP, lib.RolePlanner)
+	third := newIdentity(t, "third-planner", lib.SupplierMSP, lib.RolePlanner)
+
+	// 法定人数为 1 时提案立即执行：添加第二个管理员并把法定人数改为 2
+	n.expect(t, shim.OK, n.planner, "supplierProposeAdminChange", n.supplier, lib.AdminAdd, second.id)
+	n.expect(t, shim.OK, n.planner, "supplierProposeAdminChange", n.supplier, lib.AdminSetQuorum, "2")
+
+	// 只有发起者可以撤回待批准的提案，撤回后不能再批准
+	resp := n.expect(t, shim.OK, n.planner, "supplierProposeAdminChange", n.supplier, lib.AdminAdd, third.id)
+	var proposal lib.AdminProposal
+	if err := json.Unmarshal(resp.Payload, &proposal); err != nil || proposal.State != lib.ProposalPending {
+		t.Fatalf("proposal = %s, %v", resp.Payload, err)
+	}
+	n.expect(t, 403, second, "supplierCancelAdminChange", n.supplier, proposal.ProposalID)
+	n.expect(t, shim.OK, n.planner, "supplierCancelAdminChange", n.supplier, proposal.ProposalID)
+	n.expect(t, 400, second, "supplierApproveAdminChange", n.supplier, proposal.ProposalID)
+	n.expect(t, 400, n.planner, "supplierCancelAdminChange", n.supplier, proposal.ProposalID)
+
+	// 超过批准期限的提案不能再批准，查看时显示为已过期
+	resp = n.expect(t, shim.OK, n.planner, "supplierProposeAdminChange", n.supplier, lib.AdminAdd, third.id)
+	if err := json.Unmarshal(resp.Payload, &proposal); err != nil {
+		t.Fatal(err)
+	}
+	n.now = n.now.AddDate(0, 0, lib.AdminProposalDays+1)
+	n.expect(t, 400, second, "supplierApproveAdminChange", n.supplier, proposal.ProposalID)
+	for _, p := range n.adminProposals(t, second) {
+		if p.ProposalID == proposal.ProposalID && p.State != lib.ProposalExpired {
+			t.Fatalf("expired proposal state = %s", p.State)
+		}
+	}
+	// 过期的提案仍可由发起者撤回
+	n.expect(t, shim.OK, n.planner, "supplierCancelAdminChange", n.supplier, proposal.ProposalID)
+	if admins := n.getSupplier(t).Admins; utils.ContainsName(admins, third.id) {
+		t.Fatalf("admins = %v, want no third admin", admins)
+	}
+}
+
+func TestRetailerMembers(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+	warehouse := newIdentity(t, "warehouse", lib.RetailerMSP, lib.RoleWarehouse)
+
+	// 只有注册身份可以修改成员列表
+	n.expect(t, 403, n.intruder, "retailerAddMember", n.retailer, warehouse.id)
+	n.expect(t, 403, warehouse, "retailerUpdateInventory", n.retailer, "3")
+	n.expect(t, shim.OK, n.pharmacy, "retailerAddMember", n.retailer, warehouse.id)
+	n.expect(t, 400, n.pharmacy, "retailerAddMember", n.retailer, warehouse.id)
+	n.expect(t, 403, warehouse, "retailerAddMember", n.retailer, n.intruder.id)
+
+	// 成员按自己证书的角色操作：仓库人员可以上报库存，但不能回应补货方案
+	n.expect(t, shim.OK, warehouse, "retailerUpdateInventory", n.retailer, "3")
+	n.expect(t, 403, warehouse, "retailerResponseScheme", n.retailer, "1")
+
+	n.expect(t, shim.OK, n.pharmacy, "retailerRemoveMember", n.retailer, warehouse.id)
+	n.expect(t, 403, warehouse, "retailerUpdateInventory", n.retailer, "4")
+	n.expect(t, 400, n.pharmacy, "retailerRemoveMember", n.retailer, warehouse.id)
+}
+
+func TestSupplierAdminSetsPermission(t *testing.T) {
+	n := newTestNetwork(t)
+
+	// 供应商管理员指定供应商名称后可以修改权限表，不指定时只有通道管理员可以修改
+	n.expect(t, 403, n.planner, "setPermission", "supplierViewSchemes", lib.RolePlanner)
+	n.expect(t, 403, n.outsider, "setPermission", "supplierViewSchemes", lib.RolePlanner, n.supplier)
+	n.expect(t, 400, n.planner, "setPermission", "setPermission", lib.RolePlanner, n.supplier)
+	n.expect(t, shim.OK, n.planner, "setPermission", "supplierViewSchemes", lib.RoleAuditor, n.supplier)
+	n.expect(t, 403, n.planner, "supplierViewSchemes", n.supplier)
+	n.expect(t, shim.OK, n.admin, "setPermission", "supplierViewSchemes", lib.RolePlanner)
+	n.expect(t, shim.OK, n.planner, "supplierViewSchemes", n.supplier)
+
+	// 停用的供应商的管理员不能再修改权限表
+	n.expect(t, shim.OK, n.planner, "supplierDeactivate", n.supplier)
+	n.expect(t, 400, n.planner, "setPermission", "supplierViewSchemes", lib.RoleAuditor, n.supplier)
+}
+
+func TestRetailerRegistrationLifecycle(t *testing.T) {
+	n := newTestNetwork(t)
+	n.registerRetailer(t, n.pharmacy, n.retailer)
+
+	// 已注册的零售商不能被重复注册覆盖
+	n.expect(t, 400, n.intruder, "retailerRegistration", n.retailer, "9", "2", "0", "5", "7", "1000", "20", "50", "3", n.supplier)
+	if retailer := n.getRetailer(t, n.retailer); retailer.Owner != n.pharmacy.id || retailer.UnitPrice != 5 {
+		t.Fatalf("retailer = %+v after a duplicate registration", retailer)
+	}
+
+	// 被否决后只有注册身份可以重新提交，重新进入待审核状态
+	n.expect(t, 400, n.pharmacy, "retailerResubmit", n.retailer, "6", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier)
+	n.expect(t, shim.OK, n.planner, "supplierAuditRegistration", n.supplier, n.retailer, "0")
+	if retailer := n.getRetailer(t, n.retailer); retailer.State != lib.Veto {
+		t.Fatalf("retailer state = %s, want %s", retailer.State, lib.Veto)
+	}
+	n.expect(t, 403, n.intruder, "retailerResubmit", n.retailer, "6", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier)
+	n.expect(t, shim.OK, n.pharmacy, "retailerResubmit", n.retailer, "6", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier)
+	if retailer := n.getRetailer(t, n.retailer); retailer.State != lib.ToBeResponded || retailer.UnitPrice != 6 {
+		t.Fatalf("retailer = %+v after resubmitting", retailer)
+	}
+	n.expect(t, shim.OK, n.planner, "supplierAuditRegistration", n.supplier, n.retailer, "1")
+
+	// 补货参数修改在供应商审核通过后生效，待审核期间不能再提交修改
+	n.expect(t, shim.OK, n.pharmacy, "retailerUpdateProfile", n.retailer, `{"lead_time":4}`)
+	n.expect(t, 400, n.pharmacy, "retailerUpdateProfile", n.retailer, `{"lead_time":6}`)
+	n.expect(t, 400, n.pharmacy, "retailerUpdateProfile", n.retailer, `{"unknown":1}`)
+	if retailer := n.getRetailer(t, n.retailer); retailer.LeadTime != 2 || retailer.ProfileState != lib.ToBeResponded {
+		t.Fatalf("retailer = %+v before the profile audit", retailer)
+	}
+	n.expect(t, shim.OK, n.planner, "supplierAuditProfile", n.supplier, n.retailer, "1")
+	if retailer := n.getRetailer(t, n.retailer); retailer.LeadTime != 4 || retailer.ProfileState != lib.Pass {
+		t.Fatalf("retailer = %+v after the profile audit", retailer)
+	}
+	n.expect(t, 400, n.planner, "supplierAuditProfile", n.supplier, n.retailer, "1")
+}
+
+func TestMigrateLegacyLayout(t *testing.T) {
+	cc := new(MedicalSystem)
+	s := &testStub{MockStub: shim.NewMockStub("vmi", cc), cc: cc, now: time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)}
+	admin := newIdentity(t, "admin", lib.SupplierMSP, "")
+	pharmacy := newIdentity(t, "pharmacy", lib.RetailerMSP, lib.RoleBuyer)
+
+	// 旧版本布局：零售商以名称为 key，补货方案保存在map中或以 Scheme- 加零售商名称为 key
+	s.MockTransactionStart("legacy")
+	s.PutState(legacyKeyOfSupplier, []byte("gongying1"))
+	s.PutState("lingshou1", []byte(`{"retailer_name":"lingshou1","unit_price":5,"review_cycle":3,"state":"Pass"}`))
+	s.PutState(legacyKeyOfSchemesMap, []byte(`{"lingshou1":{"reorder_quantity":20,"unit_price":5,"response_results":"ToBeResponded"},"ghost":{"reorder_quantity":7,"response_results":"ToBeResponded"}}`))
+	s.PutState(legacySchemeKeyPrefix+"ghost2", []byte(`{"reorder_quantity":3,"response_results":"ToBeResponded"}`))
+	s.MockTransactionEnd("legacy")
+
+	if resp := s.call(admin, true, []string{"init"}); resp.Status != shim.OK {
+		t.Fatalf("Init: status %d (%s)", resp.Status, resp.Message)
+	}
+	if resp := s.invoke(pharmacy, "retailerViewScheme", "lingshou1"); resp.Status != 400 {
+		t.Fatalf("invoke before migration: status %d (%s)", resp.Status, resp.Message)
+	}
+	// 每个零售商都必须指定所有者，只有通道管理员可以迁移
+	if resp := s.invoke(admin, "migrateLedger"); resp.Status != 400 {
+		t.Fatalf("migrate without owners: status %d (%s)", resp.Status, resp.Message)
+	}
+	owners := fmt.Sprintf(`{"lingshou1":%q}`, pharmacy.id)
+	if resp := s.invoke(pharmacy, "migrateLedger", owners); resp.Status != 403 {
+		t.Fatalf("migrate by retailer: status %d (%s)", resp.Status, resp.Message)
+	}
+	resp := s.invoke(admin, "migrateLedger", owners)
+	if resp.Status != shim.OK {
+		t.Fatalf("migrate: status %d (%s)", resp.Status, resp.Message)
+	}
+	var res migrateResult
+	if err := json.Unmarshal(resp.Payload, &res); err != nil {
+		t.Fatal(err)
+	}
+	// 找不到零售商的补货方案保留在原来的 key 中，map 中有这样的方案时保留整个 map
+	// MockStub 的范围查询包含组合键（Fabric 中不包含），比较时忽略
+	var skipped []string
+	for _, key := range res.Skipped {
+		if !strings.HasPrefix(key, "\x00") {
+			skipped = append(skipped, key)
+		}
+	}
+	want := []string{legacySchemeKeyPrefix + "ghost2", legacyKeyOfSchemesMap}
+	if res.Suppliers != 1 || res.Retailers != 1 || res.Schemes != 1 || !equalStrings(skipped, want) {
+		t.Fatalf("migrate result = %+v, want skipped %v", res, want)
+	}
+	s.MockTransactionStart("read")
+	for _, key := range want {
+		if value, _ := s.GetState(key); value == nil {
+			t.Fatalf("skipped key %s was deleted", key)
+		}
+	}
+	if value, _ := s.GetState("lingshou1"); value != nil {
+		t.Fatalf("migrated key lingshou1 was kept")
+	}
+	s.MockTransactionEnd("read")
+
+	// 迁移的补货方案从迁移时起算回应期限，到期后不能再回应
+	resp = s.invoke(pharmacy, "retailerViewScheme", "lingshou1")
+	var scheme lib.ReplenishmentScheme
+	if err := json.Unmarshal(resp.Payload, &scheme); err != nil || resp.Status != shim.OK {
+		t.Fatalf("view scheme: status %d (%s), %v", resp.Status, resp.Message, err)
+	}
+	if want := s.now.AddDate(0, 0, 3); !scheme.ResponseDeadline.Equal(want) {
+		t.Fatalf("response deadline = %v, want %v", scheme.ResponseDeadline, want)
+	}
+	s.now = s.now.AddDate(0, 0, 4)
+	if resp := s.invoke(pharmacy, "retailerResponseScheme", "lingshou1", "1"); resp.Status != 400 {
+		t.Fatalf("respond to an expired scheme: status %d (%s)", resp.Status, resp.Message)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHistoryViewerAuditors(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+	retailerAuditor := newIdentity(t, "retailer-auditor", lib.RetailerMSP, lib.RoleAuditor)
+	supplierAuditor := newIdentity(t, "supplier-auditor", lib.SupplierMSP, lib.RoleAuditor)
+
+	// MockStub 不支持 GetHistoryForKey，直接验证查看历史的权限
+	check := func(caller *identity) error {
+		retailer := n.getRetailer(t, n.retailer)
+		n.creator = caller.creator
+		n.MockTransactionStart("check")
+		defer n.MockTransactionEnd("check")
+		return checkHistoryViewer(n, retailer)
+	}
+	if err := check(retailerAuditor); err == nil {
+		t.Fatal("a retailer auditor who is not a member can view the history")
+	}
+	if err := check(supplierAuditor); err != nil {
+		t.Fatalf("supplier auditor: %v", err)
+	}
+	n.expect(t, shim.OK, n.pharmacy, "retailerAddMember", n.retailer, retailerAuditor.id)
+	if err := check(retailerAuditor); err != nil {
+		t.Fatalf("retailer auditor added as a member: %v", err)
+	}
+}
+
+func TestRetailerConfigScopedToServingSupplier(t *testing.T) {
+	n := newTestNetwork(t)
+	other := newIdentity(t, "other-planner", lib.SupplierMSP, lib.RolePlanner)
+	n.expect(t, shim.OK, n.admin, "supplierRegistration", "gongying2", other.id)
+
+	// 零售商绑定了两个供应商，由审核通过的 gongying1 负责补货
+	n.expect(t, shim.OK, n.pharmacy, "retailerRegistration", n.retailer, "5", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier+",gongying2")
+	n.expect(t, shim.OK, n.planner, "supplierAuditRegistration", n.supplier, n.retailer, "1")
+	n.expect(t, shim.OK, n.planner, "supplierSetRetailerConfig", n.supplier, n.retailer, `{"rounding_multiple":6}`)
+
+	// 只绑定而不负责补货的供应商不能查看该零售商的配置
+	n.expect(t, 403, other, "supplierSetRetailerConfig", "gongying2", n.retailer, `{"rounding_multiple":2}`)
+	n.expect(t, 403, other, "supplierViewConfig", "gongying2", n.retailer)
+	n.expect(t, shim.OK, n.planner, "supplierViewConfig", n.supplier, n.retailer)
+}
+
+func TestRegisterWithStorageCapacity(t *testing.T) {
+	n := newTestNetwork(t)
+
+	// 最大存储容量不能为负数
+	n.expect(t, 400, n.pharmacy, "retailerRegistration", n.retailer, "5", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier, "0", "0", "0", "-1")
+	// 注册时指定最大存储容量 15：补到最大库存 25 需要 20，按容量只补 10
+	n.expect(t, shim.OK, n.pharmacy, "retailerRegistration", n.retailer, "5", "2", "5", "5", "7", "1000", "20", "50", "3", n.supplier, "0", "0", "0", "15")
+	n.expect(t, shim.OK, n.planner, "supplierAuditRegistration", n.supplier, n.retailer, "1")
+	if retailer := n.getRetailer(t, n.retailer); retailer.StorageCapacity != 15 {
+		t.Fatalf("storage capacity = %d, want 15", retailer.StorageCapacity)
+	}
+	if scheme := n.latestScheme(t, n.retailer); scheme.ReorderQuantity != 10 {
+		t.Fatalf("reorder quantity = %d, want 10", scheme.ReorderQuantity)
+	}
+}
+
+func TestSupplierOverdueReports(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+	other := newIdentity(t, "other-planner", lib.SupplierMSP, lib.RolePlanner)
+	n.expect(t, shim.OK, n.admin, "supplierRegistration", "gongying2", other.id)
+	n.expect(t, shim.OK, n.intruder, "retailerRegistration", "lingshou2", "5", "2", "5", "5", "7", "1000", "20", "50", "3", "gongying2")
+
+	overdue := func(caller *identity, supplierName string) []overdueRetailer {
+		t.Helper()
+		resp := n.expect(t, shim.OK, caller, "supplierOverdueReports", supplierName)
+		var list []overdueRetailer
+		if err := json.Unmarshal(resp.Payload, &list); err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+	// 上报周期 7 天，允许偏差 1 天
+	n.now = n.startTime.AddDate(0, 0, 7)
+	if list := overdue(n.planner, n.supplier); len(list) != 0 {
+		t.Fatalf("overdue = %+v before the due time", list)
+	}
+	n.now = n.startTime.AddDate(0, 0, 10)
+	list := overdue(n.planner, n.supplier)
+	if len(list) != 1 || list[0].RetailerName != n.retailer || !list[0].ReviewMissed || list[0].DaysOverdue != 2 {
+		t.Fatalf("overdue = %+v, want %s two days overdue", list, n.retailer)
+	}
+	// 其他供应商只看到自己负责补货的零售商，未审核的零售商不在其中
+	if list := overdue(other, "gongying2"); len(list) != 0 {
+		t.Fatalf("overdue of gongying2 = %+v", list)
+	}
+}
+
+func TestClaimAcknowledgedWithCredit(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+	n.expect(t, shim.OK, n.pharmacy, "retailerResponseScheme", n.retailer, "1")
+	n.expect(t, shim.OK, n.planner, "supplierShipOrder", n.supplier, n.retailer, "1")
+	n.expect(t, shim.OK, n.planner, "supplierDeliverOrder", n.supplier, n.retailer, "1")
+
+	// 订货 20：到货 18、短缺 2，其中 1 件损坏，只有 17 件计入库存
+	n.expect(t, shim.OK, n.pharmacy, "retailerReceiveOrder", n.retailer, "1", "18", "2", "1")
+	if retailer := n.getRetailer(t, n.retailer); retailer.Inventory != 22 || retailer.OnOrder != 0 || retailer.InTransit != 0 {
+		t.Fatalf("retailer = %+v after the receipt", retailer)
+	}
+	resp := n.expect(t, shim.OK, n.planner, "supplierViewClaims", n.supplier)
+	var claims []lib.Claim
+	if err := json.Unmarshal(resp.Payload, &claims); err != nil || len(claims) != 1 || claims[0].ClaimValue != 15 {
+		t.Fatalf("open claims = %s, %v", resp.Payload, err)
+	}
+
+	// 确认索赔时按索赔金额记录退款
+	n.expect(t, 403, n.outsider, "supplierResolveClaim", n.supplier, n.retailer, "1", "1")
+	resp = n.expect(t, shim.OK, n.planner, "supplierResolveClaim", n.supplier, n.retailer, "1", "1", "credit note issued")
+	var claim lib.Claim
+	if err := json.Unmarshal(resp.Payload, &claim); err != nil || claim.State != lib.ClaimAcknowledged || claim.CreditValue != 15 {
+		t.Fatalf("claim = %s, %v", resp.Payload, err)
+	}
+	n.expect(t, 400, n.planner, "supplierResolveClaim", n.supplier, n.retailer, "1", "0")
+	resp = n.expect(t, shim.OK, n.planner, "supplierViewClaims", n.supplier)
+	if err := json.Unmarshal(resp.Payload, &claims); err != nil || len(claims) != 0 {
+		t.Fatalf("open claims = %s, %v", resp.Payload, err)
+	}
+
+	// 缺少的数量由下次上报库存后生成的补货方案补足：库存 22 消耗到 8，低于订购点 10，补到 25
+	n.now = n.startTime.AddDate(0, 0, 7)
+	n.expect(t, shim.OK, n.pharmacy, "retailerUpdateInventory", n.retailer, "8")
+	if scheme := n.latestScheme(t, n.retailer); scheme.ReorderQuantity != 17 {
+		t.Fatalf("reorder quantity = %d, want 17", scheme.ReorderQuantity)
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+
+	// 同意补货方案后创建采购订单，收货前不计入库存量
+	n.expect(t, shim.OK, n.pharmacy, "retailerResponseScheme", n.retailer, "1")
+	if retailer := n.getRetailer(t, n.retailer); retailer.Inventory != 5 || retailer.OnOrder != 20 {
+		t.Fatalf("retailer = %+v after accepting the scheme", retailer)
+	}
+	if supplier := n.getSupplier(t); supplier.Stock != 980 || supplier.Reserved != 0 {
+		t.Fatalf("supplier stock = %d, reserved = %d, want 980 and 0", supplier.Stock, supplier.Reserved)
+	}
+
+	// 采购订单只能按 确认 -> 发货 -> 送达 -> 收货 的顺序推进
+	n.expect(t, 400, n.planner, "supplierDeliverOrder", n.supplier, n.retailer, "1")
+	n.expect(t, 400, n.pharmacy, "retailerReceiveOrder", n.retailer, "1")
+	n.expect(t, 403, n.outsider, "supplierShipOrder", n.supplier, n.retailer, "1")
+	n.expect(t, shim.OK, n.planner, "supplierShipOrder", n.supplier, n.retailer, "1")
+	if retailer := n.getRetailer(t, n.retailer); retailer.InTransit != 20 {
+		t.Fatalf("in transit = %d, want 20", retailer.InTransit)
+	}
+	n.expect(t, 400, n.planner, "supplierShipOrder", n.supplier, n.retailer, "1")
+	n.expect(t, shim.OK, n.planner, "supplierDeliverOrder", n.supplier, n.retailer, "1")
+
+	// 只有零售商可以收货，数量必须与订货数量一致
+	n.expect(t, 403, n.intruder, "retailerReceiveOrder", n.retailer, "1")
+	n.expect(t, 400, n.pharmacy, "retailerReceiveOrder", n.retailer, "1", "18", "1", "0")
+	n.expect(t, 400, n.pharmacy, "retailerReceiveOrder", n.retailer, "1", "18", "2", "19")
+	n.expect(t, shim.OK, n.pharmacy, "retailerReceiveOrder", n.retailer, "1")
+	n.expect(t, 400, n.pharmacy, "retailerReceiveOrder", n.retailer, "1")
+	retailer := n.getRetailer(t, n.retailer)
+	if retailer.Inventory != 25 || retailer.OnOrder != 0 || retailer.InTransit != 0 || retailer.ReceivedSinceReport != 20 {
+		t.Fatalf("retailer = %+v after the receipt", retailer)
+	}
+
+	resp := n.expect(t, shim.OK, n.planner, "viewOrders", n.retailer)
+	var orders []lib.PurchaseOrder
+	if err := json.Unmarshal(resp.Payload, &orders); err != nil || len(orders) != 1 {
+		t.Fatalf("orders = %s, %v", resp.Payload, err)
+	}
+	if order := orders[0]; order.State != lib.OrderReceived || order.ReceivedQuantity != 20 || order.TotalPrice != 100 {
+		t.Fatalf("order = %+v", order)
+	}
+	n.expect(t, 403, n.intruder, "viewOrders", n.retailer)
+}
+
+func TestNegotiationTurnTaking(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+
+	// 供应商只能回应零售商的还价，交货日期不能早于当天
+	n.expect(t, 400, n.planner, "supplierCounterScheme", n.supplier, n.retailer, "18", "2020-05-03")
+	n.expect(t, 400, n.pharmacy, "retailerCounterScheme", n.retailer, "15", "2020-04-30")
+	n.expect(t, 403, n.intruder, "retailerCounterScheme", n.retailer, "15", "2020-05-03")
+
+	// 零售商还价后等待供应商回应，不能再次还价或自行同意
+	n.expect(t, shim.OK, n.pharmacy, "retailerCounterScheme", n.retailer, "15", "2020-05-03")
+	n.expect(t, 400, n.pharmacy, "retailerCounterScheme", n.retailer, "16", "2020-05-03")
+	n.expect(t, 400, n.pharmacy, "retailerResponseScheme", n.retailer, "1")
+
+	// 供应商再次还价后轮到零售商，供应商不能同意自己的还价
+	n.expect(t, 403, n.outsider, "supplierCounterScheme", n.supplier, n.retailer, "18", "2020-05-04")
+	n.expect(t, shim.OK, n.planner, "supplierCounterScheme", n.supplier, n.retailer, "18", "2020-05-04")
+	n.expect(t, 400, n.planner, "supplierCounterScheme", n.supplier, n.retailer, "19", "2020-05-04")
+	n.expect(t, 400, n.planner, "supplierAcceptCounter", n.supplier, n.retailer)
+
+	scheme := n.latestScheme(t, n.retailer)
+	if scheme.ResponseResults != lib.Negotiating || len(scheme.Negotiation) != 2 {
+		t.Fatalf("scheme = %+v after two counters", scheme)
+	}
+	for i, party := range []string{lib.PartyRetailer, lib.PartySupplier} {
+		if round := scheme.Negotiation[i]; round.Round != i+1 || round.Party != party {
+			t.Fatalf("round %d = %+v, want party %s", i+1, round, party)
+		}
+	}
+
+	// 零售商同意供应商的还价，按还价的数量与交货日期创建采购订单
+	n.expect(t, shim.OK, n.pharmacy, "retailerResponseScheme", n.retailer, "1")
+	resp := n.expect(t, shim.OK, n.pharmacy, "viewOrders", n.retailer)
+	var orders []lib.PurchaseOrder
+	if err := json.Unmarshal(resp.Payload, &orders); err != nil || len(orders) != 1 {
+		t.Fatalf("orders = %s, %v", resp.Payload, err)
+	}
+	if order := orders[0]; order.Quantity != 18 || order.DeliveryDate != "2020-05-04" {
+		t.Fatalf("order = %+v, want 18 delivered on 2020-05-04", order)
+	}
+	n.expect(t, 400, n.pharmacy, "retailerCounterScheme", n.retailer, "15", "2020-05-05")
+}
+
+func TestSupplierAcceptsCounter(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+
+	n.expect(t, shim.OK, n.pharmacy, "retailerCounterScheme", n.retailer, "12", "2020-05-03")
+	n.expect(t, shim.OK, n.planner, "supplierAcceptCounter", n.supplier, n.retailer)
+	if scheme := n.latestScheme(t, n.retailer); scheme.ResponseResults != lib.Pass {
+		t.Fatalf("scheme state = %s, want %s", scheme.ResponseResults, lib.Pass)
+	}
+	if retailer := n.getRetailer(t, n.retailer); retailer.OnOrder != 12 {
+		t.Fatalf("on order = %d, want 12", retailer.OnOrder)
+	}
+	n.expect(t, 400, n.planner, "supplierAcceptCounter", n.supplier, n.retailer)
+}
+
+// 读取账本中零售商的全部补货方案，按序号排列
+func (n *testNetwork) schemeStates(t *testing.T) []string {
+	t.Helper()
+	n.MockTransactionStart("read")
+	defer n.MockTransactionEnd("read")
+	schemes, err := listSchemes(n, n.retailer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		states = append(states, scheme.ResponseResults)
+	}
+	return states
+}
+
+func TestSchemeStateMachine(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+
+	// 回应期限内生成新补货方案时取代尚未回应的方案（审查周期 3 天，提前上报只做标记）
+	n.now = n.startTime.AddDate(0, 0, 2)
+	n.expect(t, shim.OK, n.pharmacy, "retailerUpdateInventory", n.retailer, "3")
+	if states := n.schemeStates(t); !equalStrings(states, []string{lib.Superseded, lib.ToBeResponded}) {
+		t.Fatalf("scheme states = %v", states)
+	}
+
+	// 否决后的补货方案不能再同意或还价
+	n.expect(t, shim.OK, n.pharmacy, "retailerResponseScheme", n.retailer, "0")
+	n.expect(t, 400, n.pharmacy, "retailerResponseScheme", n.retailer, "1")
+	n.expect(t, 400, n.pharmacy, "retailerCounterScheme", n.retailer, "10", "2020-05-10")
+	if states := n.schemeStates(t); !equalStrings(states, []string{lib.Superseded, lib.Veto}) {
+		t.Fatalf("scheme states = %v", states)
+	}
+
+	// 同意后的补货方案不能再否决，也不会被新的补货方案取代
+	n.now = n.startTime.AddDate(0, 0, 9)
+	n.expect(t, shim.OK, n.pharmacy, "retailerUpdateInventory", n.retailer, "2")
+	n.expect(t, shim.OK, n.pharmacy, "retailerResponseScheme", n.retailer, "1")
+	n.expect(t, 400, n.pharmacy, "retailerResponseScheme", n.retailer, "0")
+	n.now = n.startTime.AddDate(0, 0, 16)
+	n.expect(t, shim.OK, n.pharmacy, "retailerUpdateInventory", n.retailer, "1")
+	states := n.schemeStates(t)
+	if len(states) != 4 || !equalStrings(states[:3], []string{lib.Superseded, lib.Veto, lib.Pass}) {
+		t.Fatalf("scheme states = %v", states)
+	}
+}
+
+func TestSchemeViewsDoNotWriteExpiry(t *testing.T) {
+	n := newTestNetwork(t)
+	n.passRetailer(t)
+	auditor := newIdentity(t, "retailer-auditor", lib.RetailerMSP, lib.RoleAuditor)
+	n.expect(t, shim.OK, n.pharmacy, "retailerAddMember", n.retailer, auditor.id)
+
+	viewState := func(caller *identity, function string, args ...string) string {
+		t.Helper()
+		resp := n.expect(t, shim.OK, caller, function, args...)
+		var scheme lib.ReplenishmentScheme
+		if err := json.Unmarshal(resp.Payload, &scheme); err != nil {
+			t.Fatal(err)
+		}
+		return scheme.ResponseResults
+	}
+	// 审查周期 3 天，超过回应期限后查看时显示为已过期
+	n.now = n.startTime.AddDate(0, 0, 4)
+	if state := viewState(n.pharmacy, "retailerViewScheme", n.retailer); state != lib.Expired {
+		t.Fatalf("retailerViewScheme state = %s, want %s", state, lib.Expired)
+	}
+	if state := viewState(auditor, "viewScheme", n.retailer); state != lib.Expired {
+		t.Fatalf("viewScheme state = %s, want %s", state, lib.Expired)
+	}
+	resp := n.expect(t, shim.OK, n.planner, "supplierViewSchemes", n.supplier)
+	var schemes []lib.ReplenishmentScheme
+	if err := json.Unmarshal(resp.Payload, &schemes); err != nil || len(schemes) != 1 || schemes[0].ResponseResults != lib.Expired {
+		t.Fatalf("supplier schemes = %s, %v", resp.Payload, err)
+	}
+	resp = n.expect(t, shim.OK, n.planner, "supplierExpiringSchemes", n.supplier, "2")
+	if err := json.Unmarshal(resp.Payload, &schemes); err != nil || len(schemes) != 0 {
+		t.Fatalf("expiring schemes = %s, %v", resp.Payload, err)
+	}
+
+	// 查看函数不写入账本，过期状态只由 supplierExpireSchemes 写入
+	if scheme := n.latestScheme(t, n.retailer); scheme.ResponseResults != lib.ToBeResponded {
+		t.Fatalf("ledger scheme state = %s after views, want %s", scheme.ResponseResults, lib.ToBeResponded)
+	}
+	n.expect(t, 400, n.pharmacy, "retailerResponseScheme", n.retailer, "1")
+	resp = n.expect(t, shim.OK, n.planner, "supplierExpireSchemes", n.supplier)
+	if err := json.Unmarshal(resp.Payload, &schemes); err != nil || len(schemes) != 1 {
+		t.Fatalf("expired schemes = %s, %v", resp.Payload, err)
+	}
+	if scheme := n.latestScheme(t, n.retailer); scheme.ResponseResults != lib.Expired {
+		t.Fatalf("ledger scheme state = %s, want %s", scheme.ResponseResults, lib.Expired)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 供应商管理员处理收货差异索赔：确认或提出异议
+// 确认时按索赔金额记录退款，短缺与损坏的商品不再补发：收货时只有完好的数量计入库存，
+// 零售商下次上报库存时库存水平相应降低，由新生成的补货方案补足
+// 参数： 供应商名称 零售商名称 索赔序号 处理结果（1 确认，0 异议） [说明]
+// 返回： 索赔
+func (t *MedicalSystem) supplierResolveClaim(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 4 && len(args) != 5 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 4 or 5", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空，第四个参数必须为 0 或 1）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	if args[3] != "0" && args[3] != "1" {
+		return pb.Response{Status: 400, Message: "The resolution must be 0 or 1", Payload: nil}
+	}
+	supplierName := args[0]
+	retailerName := args[1]
+	sequence, err := strconv.Atoi(args[2])
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	}
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	claim, err := getClaim(stub, retailerName, sequence)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get claim error: %s", err), Payload: nil}
+	} else if claim == nil {
+		return pb.Response{Status: 400, Message: "The claim does not exist", Payload: nil}
+	}
+	if claim.SupplierName != supplierName {
+		return pb.Response{Status: 403, Message: "Permission denied: the claim is not against this supplier", Payload: nil}
+	}
+	if claim.State != lib.ClaimOpen {
+		return pb.Response{Status: 400, Message: "The claim has already been resolved", Payload: nil}
+	}
+
+	claim.ResolveTime, err = utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
+	}
+	if args[3] == "1" {
+		claim.State = lib.ClaimAcknowledged
+		claim.CreditValue = claim.ClaimValue
+	} else {
+		claim.State = lib.ClaimDisputed
+	}
+	if len(args) == 5 {
+		claim.Comment = args[4]
+	}
+	// 写入账本
+	err = putClaim(stub, claim)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put claim error: %s", err), Payload: nil}
+	}
+
+	// 发出索赔处理事件
+	err = emitEvent(stub, lib.EventClaimResolved, &lib.Event{
+		RetailerName: claim.RetailerName,
+		SupplierName: claim.SupplierName,
+		State:        claim.State,
+		Claim:        claim,
+	})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
+	}
+	// 序列化索赔
+	claimJSON, err := json.Marshal(claim)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Resolve successful", Payload: claimJSON}
+}
+
+// 供应商管理员查看针对该供应商的待处理索赔
+// 参数： 供应商名称
+// 返回： 索赔列表
+func (t *MedicalSystem) supplierViewClaims(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	// 通过供应商索引查询由该供应商负责补货的零售商，只读取这些零售商的索赔
+	retailerNames, err := listSupplierRetailers(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List retailers error: %s", err), Payload: nil}
+	}
+	openClaims := make([]lib.Claim, 0)
+	for _, retailerName := range retailerNames {
+		claimsList, err := listClaims(stub, retailerName)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("List claims error: %s", err), Payload: nil}
+		}
+		for _, claim := range claimsList {
+			if claim.SupplierName == supplierName && claim.State == lib.ClaimOpen {
+				openClaims = append(openClaims, claim)
+			}
+		}
+	}
+	// 序列化索赔列表
+	openClaimsJSON, err := json.Marshal(openClaims)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: openClaimsJSON}
+}
+
+// 查看零售商的全部索赔
+// 参数： 零售商名称
+// 返回： 索赔列表
+func (t *MedicalSystem) viewClaims(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商或其供应商的管理员
+	err = checkSchemeViewer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	claimsList, err := listClaims(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List claims error: %s", err), Payload: nil}
+	}
+	// 序列化索赔列表
+	claimsListJSON, err := json.Marshal(claimsList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: claimsListJSON}
+}
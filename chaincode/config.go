@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/policy"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 供应商管理员修改该供应商补货计算的默认配置，对由该供应商负责补货的零售商生效
+// 参数： 供应商名称 配置JSON（只需包含要修改的字段，如 {"residual_value":5,"rounding":"nearest"}）
+// 返回： 修改后的默认配置
+func (t *MedicalSystem) supplierSetConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+
+	supplierName := args[0]
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+
+	// 在该供应商当前默认配置的基础上应用修改
+	config, err := getReplenishmentConfig(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+	}
+	err = decodeConfigPatch(args[1], config)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid config: %s", err), Payload: nil}
+	}
+	err = checkReplenishmentConfig(config)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid config: %s", err), Payload: nil}
+	}
+
+	// 写入账本，并记录修改者身份
+	config.ModifiedMSP, config.ModifiedBy, err = utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	key, err := utils.ConstructSupplierConfigKey(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Construct key error: %s", err), Payload: nil}
+	}
+	err = putStateJSON(stub, key, config)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put config error: %s", err), Payload: nil}
+	}
+
+	// 序列化返回值
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Set config successful", Payload: configJSON}
+}
+
+// 供应商管理员设置零售商对补货配置的覆盖，覆盖的字段替换之前的覆盖，{} 表示取消覆盖
+// 参数： 供应商名称 零售商名称 覆盖的配置JSON（如 {"residual_value":10}）
+// 返回： 该零售商生效的配置
+func (t *MedicalSystem) supplierSetRetailerConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 3 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" || args[2] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+	retailerName := args[1]
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	// 只能设置由该供应商负责补货的零售商
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	if retailer.Supplier != supplierName {
+		return pb.Response{Status: 403, Message: "Permission denied: the retailer is not served by this supplier", Payload: nil}
+	}
+
+	// 覆盖应用到该供应商的默认配置上后必须仍是合法的配置
+	config, err := getReplenishmentConfig(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+	}
+	err = decodeConfigPatch(args[2], config)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid config: %s", err), Payload: nil}
+	}
+	err = checkReplenishmentConfig(config)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid config: %s", err), Payload: nil}
+	}
+
+	// 写入账本，并记录修改者身份
+	retailerConfig := &lib.RetailerConfig{
+		RetailerName: retailerName,
+		Override:     json.RawMessage(args[2]),
+	}
+	retailerConfig.ModifiedMSP, retailerConfig.ModifiedBy, err = utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	key, err := utils.ConstructRetailerConfigKey(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Construct key error: %s", err), Payload: nil}
+	}
+	err = putStateJSON(stub, key, retailerConfig)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put config error: %s", err), Payload: nil}
+	}
+
+	// 序列化返回值
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Set config successful", Payload: configJSON}
+}
+
+// 供应商管理员查看补货配置
+// 参数： 供应商名称 [零售商名称]
+// 返回： 该供应商的默认配置，指定零售商时还包括该零售商的覆盖与生效的配置
+func (t *MedicalSystem) supplierViewConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 && len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1 or 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+
+	supplierName := args[0]
+
+	// 验证调用者为该供应商的管理员，指定的零售商必须由该供应商负责补货
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	if len(args) == 2 {
+		resp = checkServedRetailer(stub, supplierName, args[1])
+		if resp != nil {
+			return *resp
+		}
+	}
+
+	// 使用匿名结构体存储要返回的内容
+	res := struct {
+		Default   *lib.ReplenishmentConfig // 供应商的默认配置
+		Override  *lib.RetailerConfig      `json:",omitempty"` // 零售商的覆盖
+		Effective *lib.ReplenishmentConfig `json:",omitempty"` // 零售商生效的配置
+	}{}
+	var err error
+	res.Default, err = getReplenishmentConfig(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+	}
+	if len(args) == 2 {
+		res.Override, err = getRetailerConfig(stub, args[1])
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+		}
+		res.Effective, err = getEffectiveConfig(stub, supplierName, args[1])
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+		}
+	}
+
+	// 序列化返回值
+	resJSON, err := json.Marshal(res)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: resJSON}
+}
+
+// 供应商管理员查看补货配置的修改记录
+// 参数： 供应商名称 [零售商名称]
+// 返回： 该供应商的默认配置或零售商覆盖的历史版本列表
+func (t *MedicalSystem) supplierConfigHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 && len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1 or 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+
+	supplierName := args[0]
+
+	// 验证调用者为该供应商的管理员，指定的零售商必须由该供应商负责补货
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	if len(args) == 2 {
+		resp = checkServedRetailer(stub, supplierName, args[1])
+		if resp != nil {
+			return *resp
+		}
+	}
+
+	var key string
+	var err error
+	if len(args) == 2 {
+		key, err = utils.ConstructRetailerConfigKey(stub, args[1])
+	} else {
+		key, err = utils.ConstructSupplierConfigKey(stub, supplierName)
+	}
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Construct key error: %s", err), Payload: nil}
+	}
+	records, err := getKeyHistory(stub, key)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get history error: %s", err), Payload: nil}
+	}
+	// 序列化历史版本列表
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: recordsJSON}
+}
+
+// 读取账本，获取供应商补货计算的默认配置，未设置或未指定供应商时返回链码内置的默认配置
+func getReplenishmentConfig(stub shim.ChaincodeStubInterface, supplierName string) (*lib.ReplenishmentConfig, error) {
+	config := lib.DefaultReplenishmentConfig
+	if supplierName == "" {
+		return &config, nil
+	}
+	key, err := utils.ConstructSupplierConfigKey(stub, supplierName)
+	if err != nil {
+		return nil, err
+	}
+	_, err = getStateJSON(stub, key, &config)
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// 读取账本，获取零售商对补货配置的覆盖，不存在时返回 nil
+func getRetailerConfig(stub shim.ChaincodeStubInterface, retailerName string) (*lib.RetailerConfig, error) {
+	key, err := utils.ConstructRetailerConfigKey(stub, retailerName)
+	if err != nil {
+		return nil, err
+	}
+	retailerConfig := new(lib.RetailerConfig)
+	found, err := getStateJSON(stub, key, retailerConfig)
+	if err != nil || !found {
+		return nil, err
+	}
+	return retailerConfig, nil
+}
+
+// 获取零售商生效的补货配置：负责补货的供应商的默认配置加上该零售商的覆盖
+func getEffectiveConfig(stub shim.ChaincodeStubInterface, supplierName string, retailerName string) (*lib.ReplenishmentConfig, error) {
+	config, err := getReplenishmentConfig(stub, supplierName)
+	if err != nil {
+		return nil, err
+	}
+	retailerConfig, err := getRetailerConfig(stub, retailerName)
+	if err != nil {
+		return nil, err
+	}
+	if retailerConfig != nil {
+		err = json.Unmarshal(retailerConfig.Override, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// 将部分字段的配置JSON应用到 config 上，未知字段与修改者字段视为错误
+func decodeConfigPatch(patch string, config *lib.ReplenishmentConfig) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(patch), &fields)
+	if err != nil {
+		return err
+	}
+	if _, ok := fields["modified_by"]; ok {
+		return fmt.Errorf("modified_by cannot be set")
+	}
+	if _, ok := fields["modified_msp"]; ok {
+		return fmt.Errorf("modified_msp cannot be set")
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(patch)))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(config)
+}
+
+// 验证补货配置的取值范围
+func checkReplenishmentConfig(config *lib.ReplenishmentConfig) error {
+	if config.ResidualValue < 0 {
+		return fmt.Errorf("residual_value cannot be negative")
+	}
+	if _, err := policy.Get(config.DefaultPolicy); err != nil {
+		return err
+	}
+	for name, params := range config.DefaultPolicyParams {
+		if err := policy.ValidateParams(name, params); err != nil {
+			return err
+		}
+	}
+	if config.Rounding != lib.RoundUp && config.Rounding != lib.RoundDown && config.Rounding != lib.RoundNearest {
+		return fmt.Errorf("rounding must be %s, %s or %s", lib.RoundUp, lib.RoundDown, lib.RoundNearest)
+	}
+	if config.RoundingMultiple < 1 {
+		return fmt.Errorf("rounding_multiple must be positive")
+	}
+	if config.ReportEnforcement != lib.ReportFlag && config.ReportEnforcement != lib.ReportReject {
+		return fmt.Errorf("report_enforcement must be %s or %s", lib.ReportFlag, lib.ReportReject)
+	}
+	if config.ReportTolerance < 0 {
+		return fmt.Errorf("report_tolerance cannot be negative")
+	}
+	if config.DemandEstimation != lib.DemandNone && config.DemandEstimation != lib.DemandMovingAverage && config.DemandEstimation != lib.DemandExponentialSmoothing {
+		return fmt.Errorf("demand_estimation must be %s, %s or %s", lib.DemandNone, lib.DemandMovingAverage, lib.DemandExponentialSmoothing)
+	}
+	if config.DemandWindow < 1 || config.DemandWindow > lib.DemandHistoryLength {
+		return fmt.Errorf("demand_window must be between 1 and %d", lib.DemandHistoryLength)
+	}
+	if config.SmoothingAlpha <= 0 || config.SmoothingAlpha > 1 {
+		return fmt.Errorf("smoothing_alpha must be in (0, 1]")
+	}
+	return nil
+}
+
+// 验证零售商存在且由该供应商负责补货，与修改零售商配置的条件一致，验证失败时返回对应的响应
+func checkServedRetailer(stub shim.ChaincodeStubInterface, supplierName string, retailerName string) *pb.Response {
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return &pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	if retailer.Supplier != supplierName {
+		return &pb.Response{Status: 403, Message: "Permission denied: the retailer is not served by this supplier", Payload: nil}
+	}
+	return nil
+}
+
+// 验证调用者为该供应商的管理员且供应商处于启用状态，验证失败时返回错误响应
+func checkActiveSupplierAdmin(stub shim.ChaincodeStubInterface, supplierName string) *pb.Response {
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return &pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	err = checkSupplierAdmin(stub, supplier)
+	if err != nil {
+		return &pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if supplier.State != lib.Active {
+		return &pb.Response{Status: 400, Message: "The supplier has been deactivated", Payload: nil}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+// 根据本次上报的库存量记录上次上报以来的需求量，并按配置的估计方法更新零售商的需求量均值
+// 需在更新库存量与上报时间之前调用；尚无上报记录、间隔为 0 或需求量为负（库存增加但没有对应的补货）时不记录
+// 不足 lib.MinDemandDays 天的间隔暂存为待合并的需求量，与之后的间隔合并为一条记录，避免极短间隔得到失真的日需求量
+func recordDemand(retailer *lib.Retailer, config *lib.ReplenishmentConfig, newInventory int, now time.Time) {
+	if retailer.LastReportTime.IsZero() || !now.After(retailer.LastReportTime) {
+		return
+	}
+	record := lib.DemandRecord{
+		ReportTime:       now,
+		Days:             now.Sub(retailer.LastReportTime).Hours() / 24,
+		OpeningInventory: retailer.Inventory - retailer.ReceivedSinceReport,
+		Received:         retailer.ReceivedSinceReport,
+		ClosingInventory: newInventory,
+		Consumption:      retailer.Inventory - newInventory,
+	}
+	if pending := retailer.PendingDemand; pending != nil {
+		record.Days += pending.Days
+		record.OpeningInventory = pending.OpeningInventory
+		record.Received += pending.Received
+		record.Consumption += pending.Consumption
+		retailer.PendingDemand = nil
+	}
+	if record.Consumption < 0 {
+		return
+	}
+	if record.Days < lib.MinDemandDays {
+		retailer.PendingDemand = &record
+		return
+	}
+	record.DailyDemand = float64(record.Consumption) / record.Days
+	retailer.DemandHistory = append(retailer.DemandHistory, record)
+	if n := len(retailer.DemandHistory); n > lib.DemandHistoryLength {
+		retailer.DemandHistory = retailer.DemandHistory[n-lib.DemandHistoryLength:]
+	}
+
+	switch config.DemandEstimation {
+	case lib.DemandMovingAverage:
+		retailer.DemandEstimate = movingAverage(retailer.DemandHistory, config.DemandWindow)
+	case lib.DemandExponentialSmoothing:
+		previous := retailer.DemandEstimate
+		if previous == 0 {
+			previous = float64(retailer.AverageDemand)
+		}
+		retailer.DemandEstimate = config.SmoothingAlpha*record.DailyDemand + (1-config.SmoothingAlpha)*previous
+	default:
+		return
+	}
+	retailer.AverageDemand = int(math.Round(retailer.DemandEstimate))
+}
+
+// 最近 window 条记录的日需求量，按天数加权：需求量之和 / 天数之和
+func movingAverage(history []lib.DemandRecord, window int) float64 {
+	if len(history) > window {
+		history = history[len(history)-window:]
+	}
+	consumption, days := 0.0, 0.0
+	for _, record := range history {
+		consumption += float64(record.Consumption)
+		days += record.Days
+	}
+	if days == 0 {
+		return 0
+	}
+	return consumption / days
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+func TestMovingAverage(t *testing.T) {
+	history := []lib.DemandRecord{
+		{Consumption: 100, Days: 10},
+		{Consumption: 30, Days: 2},
+		{Consumption: 50, Days: 3},
+	}
+	// 按天数加权：(30 + 50) / (2 + 3)
+	if got := movingAverage(history, 2); got != 16 {
+		t.Fatalf("movingAverage = %v, want 16", got)
+	}
+	// 记录少于窗口时使用全部记录
+	if got := movingAverage(history, 10); got != 12 {
+		t.Fatalf("movingAverage = %v, want 12", got)
+	}
+	if got := movingAverage(nil, 4); got != 0 {
+		t.Fatalf("movingAverage of no records = %v, want 0", got)
+	}
+}
+
+func TestRecordDemandMovingAverage(t *testing.T) {
+	last := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	retailer := &lib.Retailer{Inventory: 100, LastReportTime: last}
+	retailer.AverageDemand = 5
+	config := lib.DefaultReplenishmentConfig
+	config.DemandEstimation = lib.DemandMovingAverage
+
+	recordDemand(retailer, &config, 60, last.Add(4*24*time.Hour))
+	if len(retailer.DemandHistory) != 1 {
+		t.Fatalf("history length = %d, want 1", len(retailer.DemandHistory))
+	}
+	record := retailer.DemandHistory[0]
+	if record.Consumption != 40 || record.Days != 4 || record.DailyDemand != 10 {
+		t.Fatalf("record = %+v", record)
+	}
+	if retailer.DemandEstimate != 10 || retailer.AverageDemand != 10 {
+		t.Fatalf("estimate = %v, average demand = %d, want 10", retailer.DemandEstimate, retailer.AverageDemand)
+	}
+}
+
+func TestRecordDemandExponentialSmoothing(t *testing.T) {
+	last := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	retailer := &lib.Retailer{Inventory: 100, LastReportTime: last}
+	retailer.AverageDemand = 5
+	config := lib.DefaultReplenishmentConfig
+	config.DemandEstimation = lib.DemandExponentialSmoothing
+	config.SmoothingAlpha = 0.5
+
+	// 首次估计以需求量均值为初始值：0.5 x 10 + 0.5 x 5
+	recordDemand(retailer, &config, 60, last.Add(4*24*time.Hour))
+	if math.Abs(retailer.DemandEstimate-7.5) > 1e-9 || retailer.AverageDemand != 8 {
+		t.Fatalf("estimate = %v, average demand = %d, want 7.5 and 8", retailer.DemandEstimate, retailer.AverageDemand)
+	}
+}
+
+func TestRecordDemandSkipped(t *testing.T) {
+	last := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	config := lib.DefaultReplenishmentConfig
+	config.DemandEstimation = lib.DemandMovingAverage
+
+	// 尚无上报记录时不记录
+	retailer := &lib.Retailer{Inventory: 100}
+	recordDemand(retailer, &config, 60, last)
+	if len(retailer.DemandHistory) != 0 {
+		t.Fatal("demand recorded without a previous report")
+	}
+
+	// 库存增加但没有对应的补货时不记录
+	retailer = &lib.Retailer{Inventory: 100, LastReportTime: last}
+	recordDemand(retailer, &config, 120, last.Add(24*time.Hour))
+	if len(retailer.DemandHistory) != 0 {
+		t.Fatal("negative demand recorded")
+	}
+
+	// 默认不估计需求量，只记录需求量
+	retailer = &lib.Retailer{Inventory: 100, LastReportTime: last}
+	retailer.AverageDemand = 5
+	recordDemand(retailer, &lib.DefaultReplenishmentConfig, 60, last.Add(4*24*time.Hour))
+	if len(retailer.DemandHistory) != 1 || retailer.AverageDemand != 5 || retailer.DemandEstimate != 0 {
+		t.Fatalf("default estimation changed the demand: %+v", retailer)
+	}
+}
+
+func TestRecordDemandMergesShortIntervals(t *testing.T) {
+	last := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	retailer := &lib.Retailer{Inventory: 100, LastReportTime: last}
+	retailer.AverageDemand = 5
+	config := lib.DefaultReplenishmentConfig
+	config.DemandEstimation = lib.DemandExponentialSmoothing
+
+	// 间隔不足一天时暂存，不更新需求量的估计
+	now := last.Add(time.Hour)
+	recordDemand(retailer, &config, 90, now)
+	if len(retailer.DemandHistory) != 0 || retailer.PendingDemand == nil || retailer.AverageDemand != 5 {
+		t.Fatalf("short interval recorded: history %v, pending %v, average demand %d", retailer.DemandHistory, retailer.PendingDemand, retailer.AverageDemand)
+	}
+	retailer.Inventory, retailer.LastReportTime = 90, now
+
+	// 与之后的间隔合并为一条记录：100 - 70 = 30，共 2 天
+	recordDemand(retailer, &config, 70, last.Add(48*time.Hour))
+	if retailer.PendingDemand != nil || len(retailer.DemandHistory) != 1 {
+		t.Fatalf("intervals not merged: history %v, pending %v", retailer.DemandHistory, retailer.PendingDemand)
+	}
+	record := retailer.DemandHistory[0]
+	if record.OpeningInventory != 100 || record.Consumption != 30 || record.Days != 2 || record.DailyDemand != 15 {
+		t.Fatalf("record = %+v", record)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 查看零售商记录的全部历史版本
+// 参数： 零售商名称
+// 返回： 按时间顺序排列的历史版本列表（交易 ID、时间、提交者身份与记录内容）
+func (t *MedicalSystem) getRetailerHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商的注册身份或成员、其供应商的管理员，或为供应商组织的审计员
+	err = checkHistoryViewer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	key, err := utils.ConstructRetailerKey(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Construct key error: %s", err), Payload: nil}
+	}
+	records, err := getKeyHistory(stub, key)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get history error: %s", err), Payload: nil}
+	}
+	// 序列化历史版本列表
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: recordsJSON}
+}
+
+// 查看补货方案的全部历史版本，不指定补货方案 ID 时查看最新的补货方案
+// 参数： 零售商名称 [补货方案ID]
+// 返回： 按时间顺序排列的历史版本列表（交易 ID、时间、提交者身份与记录内容）
+func (t *MedicalSystem) getSchemeHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 && len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1 or 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商的注册身份或成员、其供应商的管理员，或为供应商组织的审计员
+	err = checkHistoryViewer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	schemeID := ""
+	if len(args) == 2 {
+		schemeID = args[1]
+	}
+	scheme, err := findScheme(stub, retailer, schemeID)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get scheme error: %s", err), Payload: nil}
+	} else if scheme == nil {
+		return pb.Response{Status: 400, Message: "The scheme does not exist", Payload: nil}
+	}
+
+	key, err := utils.ConstructSchemeKey(stub, retailerName, scheme.Sequence)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Construct key error: %s", err), Payload: nil}
+	}
+	records, err := getKeyHistory(stub, key)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get history error: %s", err), Payload: nil}
+	}
+	// 序列化历史版本列表
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: recordsJSON}
+}
+
+// 验证调用者为该零售商的注册身份或成员，或为其绑定的供应商的管理员，或为供应商组织中带有审计员角色的成员
+// 零售商组织的审计员需由注册身份加入为成员，不能查看同组织中其他零售商的记录
+func checkHistoryViewer(stub shim.ChaincodeStubInterface, retailer *lib.Retailer) error {
+	if checkSchemeViewer(stub, retailer) == nil {
+		return nil
+	}
+	auditor, err := hasRole(stub, lib.RoleAuditor)
+	if err != nil {
+		return err
+	}
+	if auditor {
+		mspID, _, err := utils.GetCallerIdentity(stub)
+		if err != nil {
+			return err
+		}
+		// 没有绑定供应商时供应商组织的审计员不能查看
+		if mspID == lib.SupplierMSP && len(retailer.Suppliers) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("the caller is neither a party of retailer %s nor an auditor of their organizations", retailer.RetailerName)
+}
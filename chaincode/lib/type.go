@@ -1,36 +1,496 @@
 package lib
 
+import (
+	"encoding/json"
+	"time"
+)
+
 const (
 	ToBeResponded = "ToBeResponded"
 	Pass          = "Pass"
 	Veto          = "Veto"
+	Negotiating   = "Negotiating" // 补货方案协商中
+	Superseded    = "Superseded"  // 补货方案尚未回应即被新的补货方案取代
+	Expired       = "Expired"     // 补货方案超过回应期限未回应
+)
+
+// SchemeTransitions 补货方案回应结果允许的状态转换，未列出的转换（包括已同意、已否决、已取代之后的任何转换）均被拒绝
+var SchemeTransitions = map[string][]string{
+	ToBeResponded: {Pass, Veto, Negotiating, Superseded, Expired},
+	Negotiating:   {Negotiating, Pass, Veto, Superseded, Expired},
+}
+
+// 补货方案协商的参与方
+const (
+	PartyRetailer = "Retailer"
+	PartySupplier = "Supplier"
+)
+
+// DateLayout 交货日期的格式
+const DateLayout = "2006-01-02"
+
+// 采购订单状态：同意补货方案时确认，供应商发货、送达，零售商收货后计入库存
+const (
+	OrderConfirmed = "Confirmed"
+	OrderShipped   = "Shipped"
+	OrderDelivered = "Delivered"
+	OrderReceived  = "Received"
+)
+
+// 索赔状态：收货有短缺或损坏时创建，由供应商确认或提出异议
+const (
+	ClaimOpen         = "Open"
+	ClaimAcknowledged = "Acknowledged"
+	ClaimDisputed     = "Disputed"
+)
+
+// 供应商状态
+const (
+	Active   = "Active"
+	Inactive = "Inactive"
+)
+
+// 管理员变更类型
+const (
+	AdminRegister  = "Register"  // 注册供应商时设置初始管理员
+	AdminAdd       = "Add"       // 添加管理员
+	AdminRotate    = "Rotate"    // 轮换管理员（以新身份替换旧身份）
+	AdminRevoke    = "Revoke"    // 撤销管理员
+	AdminSetQuorum = "SetQuorum" // 修改批准变更所需的法定人数
+)
+
+// 管理员变更提案状态
+const (
+	ProposalPending   = "Pending"
+	ProposalExecuted  = "Executed"
+	ProposalCancelled = "Cancelled" // 发起者撤回
+	ProposalExpired   = "Expired"   // 超过批准期限仍未执行，只在查看时显示，不写入账本
 )
 
-var (
-	ResidualValue   = 0 // 残值
-	KeyOfSupplier   = "supplier"
-	KeyOfSchemesMap = "replenishmentSchemes"
+// AdminProposalDays 管理员变更提案的批准期限（天），超过期限的提案不能再批准
+const AdminProposalDays = 7
+
+// 组织的 MSP ID，与 deploy/docker-compose.yaml 中的 CORE_PEER_LOCALMSPID 一致
+const (
+	SupplierMSP = "SupplierMSP"
+	RetailerMSP = "RetailerMSP"
+)
+
+// 证书属性名称
+const (
+	AttrRole = "role" // 组织内的角色，可以是以逗号分隔的多个角色
+)
+
+// 角色，AnyRole 表示不限制角色
+const (
+	RoleAuditor   = "auditor"
+	RoleWarehouse = "warehouse"
+	RoleBuyer     = "buyer"
+	RolePlanner   = "vmi-planner"
+	AnyRole       = "*"
+)
+
+// DefaultPermissions 各函数允许调用的角色，通道管理员可在账本上覆盖
+// 未列出的函数拒绝调用；AnyRole 的函数在函数内部自行验证调用者身份
+var DefaultPermissions = map[string][]string{
+	"supplierRegistration":       {AnyRole},
+	"supplierList":               {AnyRole},
+	"supplierDeactivate":         {AnyRole},
+	"supplierProposeAdminChange": {AnyRole},
+	"supplierApproveAdminChange": {AnyRole},
+	"supplierViewAdminProposals": {AnyRole},
+	"supplierViewAdminChanges":   {AnyRole},
+	"supplierCancelAdminChange":  {AnyRole},
+	"setPermission":              {AnyRole},
+	"viewPermissions":            {AnyRole},
+	"retailerRegistration":       {AnyRole},
+	"retailerResubmit":           {AnyRole},
+	"retailerAddMember":          {AnyRole},
+	"retailerRemoveMember":       {AnyRole},
+	"retailerUpdateProfile":      {RoleBuyer},
+	"supplierAuditProfile":       {RolePlanner},
+	"retailerViewScheme":         {RoleBuyer, RoleWarehouse, RoleAuditor},
+	"retailerResponseScheme":     {RoleBuyer},
+	"retailerUpdateInventory":    {RoleWarehouse},
+	"supplierAuditRegistration":  {RolePlanner},
+	"supplierViewSchemes":        {RolePlanner, RoleAuditor},
+	"viewScheme":                 {RoleBuyer, RoleWarehouse, RoleAuditor, RolePlanner},
+	"viewSchemeHistory":          {RoleBuyer, RoleAuditor, RolePlanner},
+	"getRetailerHistory":         {RoleAuditor, RoleBuyer, RolePlanner},
+	"getSchemeHistory":           {RoleAuditor, RoleBuyer, RolePlanner},
+	"supplierSetConfig":          {RolePlanner},
+	"supplierSetRetailerConfig":  {RolePlanner},
+	"supplierViewConfig":         {RolePlanner, RoleAuditor},
+	"supplierConfigHistory":      {RolePlanner, RoleAuditor},
+	"supplierSetPriceBreaks":     {RolePlanner},
+	"viewPriceBreaks":            {RoleBuyer, RolePlanner, RoleAuditor},
+	"supplierUpdateStock":        {RolePlanner, RoleWarehouse},
+	"supplierAllocateStock":      {RolePlanner},
+	"supplierOverdueReports":     {RolePlanner, RoleAuditor},
+	"supplierShipOrder":          {RoleWarehouse, RolePlanner},
+	"supplierDeliverOrder":       {RoleWarehouse, RolePlanner},
+	"retailerReceiveOrder":       {RoleWarehouse},
+	"viewOrders":                 {RoleBuyer, RoleWarehouse, RolePlanner, RoleAuditor},
+	"retailerCounterScheme":      {RoleBuyer},
+	"supplierCounterScheme":      {RolePlanner},
+	"supplierAcceptCounter":      {RolePlanner},
+	"supplierExpiringSchemes":    {RolePlanner, RoleAuditor},
+	"supplierExpireSchemes":      {RolePlanner},
+	"supplierResolveClaim":       {RolePlanner},
+	"supplierViewClaims":         {RolePlanner, RoleAuditor},
+	"viewClaims":                 {RoleBuyer, RoleWarehouse, RolePlanner, RoleAuditor},
+}
+
+// 账本中各类对象的组合键类型前缀
+const (
+	ObjectTypeRetailer       = "vmi.retailer"
+	ObjectTypeScheme         = "vmi.scheme"
+	ObjectTypeSupplier       = "vmi.supplier"
+	ObjectTypeAdminProposal  = "vmi.adminProposal"
+	ObjectTypeAdminChange    = "vmi.adminChange"
+	ObjectTypeConfig         = "vmi.config"
+	ObjectTypeRetailerConfig = "vmi.retailerConfig"
+	ObjectTypeSupplierConfig = "vmi.supplierConfig"
+	ObjectTypePriceBreaks    = "vmi.priceBreaks"
+	ObjectTypeOrder          = "vmi.order"
+	ObjectTypeClaim          = "vmi.claim"
+	// 供应商负责补货的零售商索引，用于按供应商查询零售商
+	ObjectTypeSupplierRetailer = "vmi.supplierRetailer"
+)
+
+// 配置项名称，与 ObjectTypeConfig 组成配置的 key
+const (
+	ConfigPermissions   = "permissions"   // 权限表
+	ConfigLedgerVersion = "ledgerVersion" // 账本数据布局版本
+	ConfigChannelAdmins = "channelAdmins" // 通道管理员的客户端身份 ID 列表，实例化或升级链码时记录调用者
+)
+
+// LedgerVersion 当前账本数据布局版本，旧版本的数据需通过 migrateLedger 迁移
+const LedgerVersion = 1
+
+// DefaultPolicy 账本上未配置默认策略时使用的策略：库存低于订购点时补货到最大库存
+const DefaultPolicy = "sS"
+
+// 供应商库存不足时的配给方式
+const (
+	AllocateProportional = "proportional" // 按补货数量的比例配给
+	AllocatePriority     = "priority"     // 按优先级从高到低满足
+	AllocateFillRate     = "fillrate"     // 使各零售商补货后的库存满足率尽量相同
+)
+
+// 库存上报相对上报周期的状态，以及提前上报的处理方式
+const (
+	ReportOnTime = "OnTime" // 按时上报
+	ReportEarly  = "Early"  // 未到上报周期提前上报
+	ReportLate   = "Late"   // 超过上报周期逾期上报
+	ReportFlag   = "flag"   // 提前上报时接受并标记
+	ReportReject = "reject" // 提前上报时拒绝
 )
 
+// 由库存上报记录估计需求量的方法
+const (
+	DemandNone                 = "none"                  // 不估计，使用零售商提交的需求量均值
+	DemandMovingAverage        = "moving_average"        // 最近若干条记录的日需求量按天数加权平均
+	DemandExponentialSmoothing = "exponential_smoothing" // 对每条记录的日需求量做指数平滑
+)
+
+// DemandHistoryLength 零售商保留的需求量记录条数
+const DemandHistoryLength = 30
+
+// MinDemandDays 一条需求量记录的最短天数，更短的上报间隔与之后的间隔合并
+const MinDemandDays = 1
+
+// 补货数量的取整规则
+const (
+	RoundUp      = "up"      // 向上取整
+	RoundDown    = "down"    // 向下取整
+	RoundNearest = "nearest" // 四舍五入
+)
+
+// DefaultReplenishmentConfig 供应商尚未设置补货配置时使用的默认配置
+var DefaultReplenishmentConfig = ReplenishmentConfig{
+	ResidualValue:     0,
+	DefaultPolicy:     DefaultPolicy,
+	Rounding:          RoundUp,
+	RoundingMultiple:  1,
+	ReportEnforcement: ReportFlag,
+	ReportTolerance:   1,
+	DemandEstimation:  DemandNone,
+	DemandWindow:      4,
+	SmoothingAlpha:    0.3,
+}
+
+// DaysPerYear 将日需求量均值换算为年需求量
+const DaysPerYear = 365
+
 // Retailer 零售商
 type Retailer struct {
-	RetailerName       string  `json:"retailer_name"`        // 零售商名称
+	RetailerName string `json:"retailer_name"` // 零售商名称
+	RetailerProfile
+	Inventory           int             `json:"inventory"`             // 库存量
+	State               string          `json:"state"`                 // 帐号状态（待审核、通过、否决）
+	Owner               string          `json:"owner"`                 // 注册该零售商的客户端身份 ID（旧版本的零售商为迁移时指定的身份）
+	Members             []string        `json:"members"`               // 注册身份加入的成员客户端身份 ID，可以按各自的角色代表该零售商操作
+	Suppliers           []string        `json:"suppliers"`             // 绑定的供应商名称列表
+	Supplier            string          `json:"supplier"`              // 负责补货的供应商（审核通过该零售商的供应商）
+	PendingProfile      json.RawMessage `json:"pending_profile"`       // 待供应商审核的补货参数修改，只包含要修改的字段
+	ProfileState        string          `json:"profile_state"`         // 补货参数修改的审核状态（待审核、通过、否决）
+	SchemeSeq           int             `json:"scheme_seq"`            // 最新补货方案的序号，0 表示尚无补货方案
+	OnOrder             int             `json:"on_order"`              // 已同意补货方案但尚未收货的数量（在途与未发货），生成补货方案时计入库存水平
+	InTransit           int             `json:"in_transit"`            // 已发货但尚未收货的数量
+	Policy              PolicySetting   `json:"policy"`                // 审核时约定的补货策略
+	LastReportTime      time.Time       `json:"last_report_time"`      // 最近一次上报库存的交易时间（审核通过时为审核时间），上报周期从该时间起算
+	ReportStatus        string          `json:"report_status"`         // 最近一次库存上报是否按上报周期（按时、提前、逾期）
+	EarlyReports        int             `json:"early_reports"`         // 提前上报库存的次数
+	LateReports         int             `json:"late_reports"`          // 逾期上报库存的次数
+	ReceivedSinceReport int             `json:"received_since_report"` // 上次上报库存后同意补货方案收到的补货数量
+	DemandEstimate      float64         `json:"demand_estimate"`       // 由库存上报记录估计的日需求量，取整后更新需求量均值
+	DemandHistory       []DemandRecord  `json:"demand_history"`        // 最近的需求量记录，最多保留 DemandHistoryLength 条
+	PendingDemand       *DemandRecord   `json:"pending_demand"`        // 不足 MinDemandDays 天、尚未计入需求量记录的间隔
+	ModifiedBy          string          `json:"modified_by"`           // 最后修改该记录的客户端身份 ID
+	ModifiedMSP         string          `json:"modified_msp"`          // 最后修改该记录的客户端所属 MSP
+}
+
+// RetailerProfile 零售商的补货参数，注册后的修改需经供应商审核
+type RetailerProfile struct {
 	UnitPrice          float64 `json:"unit_price"`           // 订货单价
 	LeadTime           int     `json:"lead_time"`            // 提前期
-	Inventory          int     `json:"inventory"`            // 库存量
 	AverageDemand      int     `json:"average_demand"`       // 需求量均值
 	UpdateCycle        int     `json:"update_cycle"`         // 上传数据的周期
 	InventoryValue     float64 `json:"inventory_value"`      // 库存商品价值
 	AnnualInterestRate float64 `json:"annual_interest_rate"` // 年利率
 	FixedOrderCost     float64 `json:"fixed_order_cost"`     // 固定订货成本
 	ReviewCycle        int     `json:"review_cycle"`         // 审查周期
-	State              string  `json:"state"`                // 帐号状态（待审核、通过、否决）
+	DemandStdDev       float64 `json:"demand_std_dev"`       // 需求量标准差
+	ServiceLevel       float64 `json:"service_level"`        // 目标周期服务水平（百分比，如 95 表示 95%），为 0 时不设安全库存
+	LeadTimeStdDev     float64 `json:"lead_time_std_dev"`    // 提前期标准差，提前期为其均值
+	StorageCapacity    int     `json:"storage_capacity"`     // 最大存储容量，补货后的库存量不能超过该值，为 0 时不限制
+}
+
+// PolicySetting 零售商的补货策略，名称为空时使用配置中的默认策略
+type PolicySetting struct {
+	Name   string             `json:"name"`   // 策略名称
+	Params map[string]float64 `json:"params"` // 策略参数，未设置的参数由补货参数推算
+}
+
+// Supplier 供应商
+type Supplier struct {
+	SupplierName string   `json:"supplier_name"` // 供应商名称
+	Admins       []string `json:"admins"`        // 管理员的客户端身份 ID 列表，拥有审核权限
+	Quorum       int      `json:"quorum"`        // 管理员变更需要的批准人数
+	ChangeSeq    int      `json:"change_seq"`    // 最新管理员变更记录的序号
+	State        string   `json:"state"`         // 状态（启用、停用）
+	Stock        int      `json:"stock"`         // 供应商库存量
+	Reserved     int      `json:"reserved"`      // 已分配给待回应补货方案的库存量，可用库存 = 库存量 - 已分配量
+}
+
+// AdminProposal 供应商管理员变更提案
+type AdminProposal struct {
+	ProposalID   string    `json:"proposal_id"`   // 提案 ID（发起提案的交易 ID）
+	SupplierName string    `json:"supplier_name"` // 供应商名称
+	Action       string    `json:"action"`        // 变更类型
+	Admin        string    `json:"admin"`         // 被添加、轮换或撤销的管理员身份 ID
+	NewAdmin     string    `json:"new_admin"`     // 轮换后的新管理员身份 ID
+	Quorum       int       `json:"quorum"`        // 新的法定人数
+	Proposer     string    `json:"proposer"`      // 发起提案的管理员身份 ID
+	Approvals    []string  `json:"approvals"`     // 已批准该提案的管理员身份 ID 列表
+	State        string    `json:"state"`         // 提案状态（待批准、已执行、已撤回）
+	CreateTime   time.Time `json:"create_time"`   // 发起时间（交易时间戳）
+	Deadline     time.Time `json:"deadline"`      // 批准期限：发起时间加上 AdminProposalDays 天
+}
+
+// AdminChange 供应商管理员变更记录，每条记录以供应商名称与序号单独保存在账本中
+type AdminChange struct {
+	Sequence     int       `json:"sequence"`      // 该供应商的变更记录序号，从 1 开始递增
+	TxID         string    `json:"tx_id"`         // 执行变更的交易 ID
+	Time         time.Time `json:"time"`          // 执行变更的时间（交易时间戳）
+	SupplierName string    `json:"supplier_name"` // 供应商名称
+	ProposalID   string    `json:"proposal_id"`   // 对应的提案 ID，注册时为空
+	Action       string    `json:"action"`        // 变更类型
+	Approvals    []string  `json:"approvals"`     // 批准该变更的管理员身份 ID 列表
+	Admins       []string  `json:"admins"`        // 变更后的管理员身份 ID 列表
+	Quorum       int       `json:"quorum"`        // 变更后的法定人数
 }
 
 // ReplenishmentScheme 补货方案
 type ReplenishmentScheme struct {
-	RetailerName    string  `json:"retailer_name"`    // 零售商名称
-	ReorderQuantity int     `json:"reorder_quantity"` // 补货数量
-	UnitPrice       float64 `json:"unit_price"`       // 单价
-	ResponseResults string  `json:"response_results"` // 回应结果
+	SchemeID          string             `json:"scheme_id"`                // 补货方案 ID（生成该方案的交易 ID）
+	Sequence          int                `json:"sequence"`                 // 该零售商的补货方案序号，从 1 开始递增
+	CreateTime        time.Time          `json:"create_time"`              // 生成时间
+	ResponseDeadline  time.Time          `json:"response_deadline"`        // 回应期限：生成时间加上零售商的审查周期，为零值时不过期
+	RetailerName      string             `json:"retailer_name"`            // 零售商名称
+	SupplierName      string             `json:"supplier_name"`            // 供应商名称
+	ReorderQuantity   int                `json:"reorder_quantity"`         // 补货数量
+	UnitPrice         float64            `json:"unit_price"`               // 单价
+	TotalPrice        float64            `json:"total_price"`              // 总价 = 补货数量 x 单价，配给或同意后按配给或约定的数量重新定价
+	PolicyQuantity    int                `json:"policy_quantity"`          // 补货策略计算的补货数量，为达到价格折扣档位而增加订货时与补货数量不同
+	Capped            bool               `json:"capped"`                   // 补货数量是否因存储容量而被削减
+	CappedBy          int                `json:"capped_by"`                // 因存储容量削减的补货数量
+	Allocated         bool               `json:"allocated"`                // 是否经过供应商的库存配给
+	AllocatedQuantity int                `json:"allocated_quantity"`       // 配给的数量，同意补货方案时按该数量补货，总价按该数量计算
+	AllocationMode    string             `json:"allocation_mode"`          // 配给方式
+	Negotiation       []NegotiationRound `json:"negotiation,omitempty"`    // 协商记录，零售商与供应商轮流提出补货数量与交货日期
+	AgreedQuantity    int                `json:"agreed_quantity"`          // 同意时约定的补货数量
+	DeliveryDate      string             `json:"delivery_date"`            // 同意时约定的交货日期（YYYY-MM-DD），未协商时为空
+	ResponseResults   string             `json:"response_results"`         // 回应结果
+	Policy            string             `json:"policy"`                   // 生成该方案使用的补货策略
+	PolicyParams      map[string]float64 `json:"policy_params"`            // 生成该方案使用的策略参数
+	CostBreakdown     *CostBreakdown     `json:"cost_breakdown,omitempty"` // 经济订货批量的成本构成
+	ModifiedBy        string             `json:"modified_by"`              // 最后修改该记录的客户端身份 ID
+	ModifiedMSP       string             `json:"modified_msp"`             // 最后修改该记录的客户端所属 MSP
+}
+
+// NegotiationRound 补货方案的一轮协商
+type NegotiationRound struct {
+	Round        int       `json:"round"`         // 轮次，从 1 开始
+	Party        string    `json:"party"`         // 提出方（零售商、供应商）
+	Quantity     int       `json:"quantity"`      // 提出的补货数量，超过存储容量时被削减
+	UnitPrice    float64   `json:"unit_price"`    // 按价格折扣表对该数量的单价
+	TotalPrice   float64   `json:"total_price"`   // 总价
+	DeliveryDate string    `json:"delivery_date"` // 提出的交货日期（YYYY-MM-DD）
+	TxID         string    `json:"tx_id"`         // 交易 ID
+	Time         time.Time `json:"time"`          // 交易时间
+	ProposedBy   string    `json:"proposed_by"`   // 提出者的客户端身份 ID
+}
+
+// PurchaseOrder 采购订单，零售商同意补货方案时创建，序号与补货方案相同
+type PurchaseOrder struct {
+	OrderID          string    `json:"order_id"`          // 采购订单 ID（同意补货方案的交易 ID）
+	Sequence         int       `json:"sequence"`          // 采购订单序号，与对应补货方案的序号相同
+	SchemeID         string    `json:"scheme_id"`         // 对应的补货方案 ID
+	RetailerName     string    `json:"retailer_name"`     // 零售商名称
+	SupplierName     string    `json:"supplier_name"`     // 供应商名称
+	Quantity         int       `json:"quantity"`          // 订货数量
+	UnitPrice        float64   `json:"unit_price"`        // 单价
+	TotalPrice       float64   `json:"total_price"`       // 总价
+	DeliveryDate     string    `json:"delivery_date"`     // 约定的交货日期（YYYY-MM-DD），未协商时为空
+	State            string    `json:"state"`             // 订单状态（已确认、已发货、已送达、已收货）
+	ConfirmTime      time.Time `json:"confirm_time"`      // 确认时间
+	ShipTime         time.Time `json:"ship_time"`         // 发货时间
+	DeliverTime      time.Time `json:"deliver_time"`      // 送达时间
+	ReceiveTime      time.Time `json:"receive_time"`      // 收货时间
+	ReceivedQuantity int       `json:"received_quantity"` // 收货时实际到货的数量（含损坏）
+	ShortQuantity    int       `json:"short_quantity"`    // 收货时短缺的数量
+	DamagedQuantity  int       `json:"damaged_quantity"`  // 收货时损坏的数量，不计入库存量
+	ModifiedBy       string    `json:"modified_by"`       // 最后修改该记录的客户端身份 ID
+	ModifiedMSP      string    `json:"modified_msp"`      // 最后修改该记录的客户端所属 MSP
+}
+
+// Claim 收货差异索赔，每个采购订单最多一个，序号与采购订单相同
+type Claim struct {
+	ClaimID         string    `json:"claim_id"`         // 索赔 ID（收货的交易 ID）
+	Sequence        int       `json:"sequence"`         // 索赔序号，与对应采购订单的序号相同
+	OrderID         string    `json:"order_id"`         // 对应的采购订单 ID
+	SchemeID        string    `json:"scheme_id"`        // 对应的补货方案 ID
+	RetailerName    string    `json:"retailer_name"`    // 零售商名称
+	SupplierName    string    `json:"supplier_name"`    // 供应商名称
+	OrderedQuantity int       `json:"ordered_quantity"` // 订货数量
+	ShortQuantity   int       `json:"short_quantity"`   // 短缺的数量
+	DamagedQuantity int       `json:"damaged_quantity"` // 损坏的数量
+	ClaimValue      float64   `json:"claim_value"`      // 索赔金额 =（短缺数量 + 损坏数量）x 单价
+	CreditValue     float64   `json:"credit_value"`     // 供应商确认后记入零售商账款的退款金额，等于索赔金额；有异议时为 0
+	State           string    `json:"state"`            // 索赔状态（待处理、已确认、有异议）
+	CreateTime      time.Time `json:"create_time"`      // 创建时间
+	ResolveTime     time.Time `json:"resolve_time"`     // 供应商处理的时间
+	Comment         string    `json:"comment"`          // 供应商处理时的说明
+	ModifiedBy      string    `json:"modified_by"`      // 最后修改该记录的客户端身份 ID
+	ModifiedMSP     string    `json:"modified_msp"`     // 最后修改该记录的客户端所属 MSP
+}
+
+// CostBreakdown 经济订货批量 EOQ = sqrt(2DK/h) 的计算过程与年度成本
+type CostBreakdown struct {
+	AnnualDemand       float64 `json:"annual_demand"`        // 年需求量 D = 需求量均值 x 365
+	OrderCost          float64 `json:"order_cost"`           // 每次订货的固定成本 K
+	HoldingCost        float64 `json:"holding_cost"`         // 单位商品年持有成本 h = 年利率 / 100 x 订货单价
+	EconomicQuantity   float64 `json:"economic_quantity"`    // 经济订货批量 sqrt(2DK/h)
+	OrdersPerYear      float64 `json:"orders_per_year"`      // 年订货次数 D / Q
+	AnnualOrderingCost float64 `json:"annual_ordering_cost"` // 年订货成本 D / Q x K
+	AnnualHoldingCost  float64 `json:"annual_holding_cost"`  // 年持有成本 Q / 2 x h
+	TotalAnnualCost    float64 `json:"total_annual_cost"`    // 年总成本
+}
+
+// PriceBreak 价格折扣档位：订货数量不低于 MinQuantity 时的单价
+type PriceBreak struct {
+	MinQuantity int     `json:"min_quantity"` // 起订数量
+	UnitPrice   float64 `json:"unit_price"`   // 单价
+}
+
+// PriceBreakTable 供应商为零售商设置的价格折扣表，订货数量低于最低档位时使用零售商的订货单价
+type PriceBreakTable struct {
+	RetailerName string       `json:"retailer_name"` // 零售商名称
+	SupplierName string       `json:"supplier_name"` // 设置该表的供应商名称
+	Breaks       []PriceBreak `json:"breaks"`        // 按起订数量升序排列的档位
+	ModifiedBy   string       `json:"modified_by"`   // 最后修改该表的客户端身份 ID
+	ModifiedMSP  string       `json:"modified_msp"`  // 最后修改该表的客户端所属 MSP
+}
+
+// DemandRecord 两次库存上报之间的需求量：期初库存 + 收到的补货 - 期末库存
+type DemandRecord struct {
+	ReportTime       time.Time `json:"report_time"`       // 本次上报库存的交易时间
+	Days             float64   `json:"days"`              // 距上次上报的天数
+	OpeningInventory int       `json:"opening_inventory"` // 上次上报的库存量
+	Received         int       `json:"received"`          // 期间收到的补货数量
+	ClosingInventory int       `json:"closing_inventory"` // 本次上报的库存量
+	Consumption      int       `json:"consumption"`       // 期间的需求量
+	DailyDemand      float64   `json:"daily_demand"`      // 日需求量
+}
+
+// ReplenishmentConfig 补货计算的配置，每个供应商的默认配置保存在账本上，零售商可单独覆盖部分字段
+type ReplenishmentConfig struct {
+	ResidualValue       int                           `json:"residual_value"`        // 残值
+	DefaultPolicy       string                        `json:"default_policy"`        // 零售商未约定补货策略时使用的策略
+	DefaultPolicyParams map[string]map[string]float64 `json:"default_policy_params"` // 各策略的默认参数，零售商约定的参数优先
+	Rounding            string                        `json:"rounding"`              // 补货数量的取整规则
+	RoundingMultiple    int                           `json:"rounding_multiple"`     // 补货数量取整为该数的整数倍（如每箱数量）
+	ReportEnforcement   string                        `json:"report_enforcement"`    // 提前上报库存的处理方式（flag 标记、reject 拒绝），逾期上报总是接受并标记
+	ReportTolerance     float64                       `json:"report_tolerance"`      // 判断上报是否按周期时允许的偏差天数
+	DemandEstimation    string                        `json:"demand_estimation"`     // 需求量的估计方法（none 不估计、moving_average 移动平均、exponential_smoothing 指数平滑）
+	DemandWindow        int                           `json:"demand_window"`         // 移动平均使用的最近记录条数
+	SmoothingAlpha      float64                       `json:"smoothing_alpha"`       // 指数平滑系数，取值 (0, 1]，越大越偏重最近的需求量
+	ModifiedBy          string                        `json:"modified_by"`           // 最后修改该配置的客户端身份 ID
+	ModifiedMSP         string                        `json:"modified_msp"`          // 最后修改该配置的客户端所属 MSP
+}
+
+// RetailerConfig 零售商对补货配置的覆盖，Override 中的字段覆盖供应商默认配置的同名字段
+type RetailerConfig struct {
+	RetailerName string          `json:"retailer_name"` // 零售商名称
+	Override     json.RawMessage `json:"override"`      // 覆盖的配置字段（ReplenishmentConfig 的部分字段）
+	ModifiedBy   string          `json:"modified_by"`   // 最后修改该配置的客户端身份 ID
+	ModifiedMSP  string          `json:"modified_msp"`  // 最后修改该配置的客户端所属 MSP
+}
+
+// HistoryRecord 账本中一个 key 的某个历史版本
+type HistoryRecord struct {
+	TxID        string          `json:"tx_id"`        // 写入该版本的交易 ID
+	Timestamp   time.Time       `json:"timestamp"`    // 交易时间
+	IsDelete    bool            `json:"is_delete"`    // 该交易是否删除了 key
+	ModifiedBy  string          `json:"modified_by"`  // 提交该交易的客户端身份 ID
+	ModifiedMSP string          `json:"modified_msp"` // 提交该交易的客户端所属 MSP
+	Value       json.RawMessage `json:"value"`        // 该版本的记录内容
+}
+
+// 链码事件名称，每个交易只发出一个事件，事件内容为 Event 的 JSON
+const (
+	EventRetailerRegistered = "RetailerRegistered" // 零售商注册
+	EventRetailerAudited    = "RetailerAudited"    // 供应商审核零售商注册，通过时附带生成的补货方案
+	EventInventoryReported  = "InventoryReported"  // 零售商上报库存，附带生成的补货方案
+	EventSchemeResponded    = "SchemeResponded"    // 零售商同意或否决补货方案，同意时附带创建的采购订单
+	EventSchemeCountered    = "SchemeCountered"    // 零售商或供应商对补货方案提出还价
+	EventOrderUpdated       = "OrderUpdated"       // 采购订单发货、送达或收货，收货有差异时附带创建的索赔
+	EventClaimResolved      = "ClaimResolved"      // 供应商确认索赔或提出异议
+)
+
+// Event 链码事件内容
+type Event struct {
+	Type         string               `json:"type"`                    // 事件名称
+	TxID         string               `json:"tx_id"`                   // 交易 ID
+	Timestamp    time.Time            `json:"timestamp"`               // 交易时间
+	RetailerName string               `json:"retailer_name"`           // 零售商名称
+	SupplierName string               `json:"supplier_name"`           // 供应商名称（零售商注册时为空）
+	State        string               `json:"state"`                   // 零售商帐号状态，或补货方案的回应结果
+	Inventory    int                  `json:"inventory"`               // 事件发生后零售商的库存量
+	Scheme       *ReplenishmentScheme `json:"scheme,omitempty"`        // 新生成或被回应的补货方案
+	ReportStatus string               `json:"report_status,omitempty"` // 库存上报是否按上报周期（库存上报事件）
+	Order        *PurchaseOrder       `json:"order,omitempty"`         // 新建或状态变化的采购订单
+	Claim        *Claim               `json:"claim,omitempty"`         // 收货差异产生或被处理的索赔
 }
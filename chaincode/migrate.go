@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 旧版本账本布局使用的 key：零售商直接以名称作为 key，补货方案以 Scheme- 加零售商名称作为 key
+const (
+	legacyKeyOfSupplier   = "supplier"             // 唯一供应商的名称
+	legacyKeyOfSchemesMap = "replenishmentSchemes" // 补货方案map
+	legacySchemeKeyPrefix = "Scheme-"
+)
+
+// 迁移结果统计
+type migrateResult struct {
+	Suppliers int      // 迁移的供应商数量
+	Retailers int      // 迁移的零售商数量
+	Schemes   int      // 迁移的补货方案数量
+	Skipped   []string // 无法识别而保留原样的 key
+}
+
+// 通道管理员将旧版本布局的账本数据一次性迁移到组合键布局，迁移后删除旧的 key
+// 旧版本的零售商没有记录注册身份，迁移时需为每个零售商指定其所有者的客户端身份 ID
+// 参数： [零售商所有者JSON（如 {"lingshou1":"<身份ID>"}），账本中没有零售商时可省略]
+// 返回： 迁移结果统计
+func (t *MedicalSystem) migrateLedger(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) > 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 0 or 1", Payload: nil}
+	}
+	owners := make(map[string]string)
+	if len(args) == 1 {
+		err := json.Unmarshal([]byte(args[0]), &owners)
+		if err != nil {
+			return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid retailer owners: %s", err), Payload: nil}
+		}
+	}
+
+	// 只有通道管理员可以执行迁移，执行者成为原有供应商的管理员
+	err := checkChannelAdmin(stub)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	_, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+
+	version, err := getLedgerVersion(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get ledger version error: %s", err), Payload: nil}
+	}
+	if version >= lib.LedgerVersion {
+		return pb.Response{Status: 400, Message: "The ledger has already been migrated", Payload: nil}
+	}
+
+	res := &migrateResult{}
+	err = migrateLegacyLayout(stub, callerID, owners, res)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Migrate error: %s", err), Payload: nil}
+	}
+
+	// 记录账本布局版本
+	err = putConfig(stub, lib.ConfigLedgerVersion, lib.LedgerVersion)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put ledger version error: %s", err), Payload: nil}
+	}
+
+	// 序列化返回值
+	resJSON, err := json.Marshal(res)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Migrate successful", Payload: resJSON}
+}
+
+// 读取账本布局版本，未记录时为 0
+func getLedgerVersion(stub shim.ChaincodeStubInterface) (int, error) {
+	version := 0
+	_, err := getConfig(stub, lib.ConfigLedgerVersion, &version)
+	return version, err
+}
+
+// 判断账本中是否存在旧版本布局的数据（旧版本的 Init 总会写入补货方案map）
+func hasLegacyLayout(stub shim.ChaincodeStubInterface) (bool, error) {
+	value, err := stub.GetState(legacyKeyOfSchemesMap)
+	if err != nil {
+		return false, err
+	}
+	return value != nil, nil
+}
+
+// 将以名称保存的数据迁移到组合键布局
+// 同一交易内读不到本交易写入的数据，先读出全部旧数据，在内存中整理后一次写入
+// 范围查询不包含组合键，得到的都是旧布局的 key
+func migrateLegacyLayout(stub shim.ChaincodeStubInterface, callerID string, owners map[string]string, res *migrateResult) error {
+	iterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		return err
+	}
+	legacy := make(map[string][]byte)
+	var keys []string
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			iterator.Close()
+			return err
+		}
+		legacy[kv.Key] = kv.Value
+		keys = append(keys, kv.Key)
+	}
+	iterator.Close()
+
+	// 补货方案：单独保存的方案优先，补货方案map中只补充缺失的方案
+	schemes := make(map[string]*lib.ReplenishmentScheme)
+	schemesMap := make(map[string]lib.ReplenishmentScheme)
+	if value, ok := legacy[legacyKeyOfSchemesMap]; ok {
+		err = json.Unmarshal(value, &schemesMap)
+		if err != nil {
+			return fmt.Errorf("unmarshal %s error: %s", legacyKeyOfSchemesMap, err)
+		}
+		for retailerName, scheme := range schemesMap {
+			scheme := scheme
+			schemes[retailerName] = &scheme
+		}
+	}
+	retailers := make(map[string]*lib.Retailer)
+	for _, key := range keys {
+		value := legacy[key]
+		switch {
+		case key == legacyKeyOfSupplier || key == legacyKeyOfSchemesMap:
+			// 单独处理
+		case strings.HasPrefix(key, legacySchemeKeyPrefix):
+			scheme := new(lib.ReplenishmentScheme)
+			err = json.Unmarshal(value, scheme)
+			if err != nil {
+				return fmt.Errorf("unmarshal %s error: %s", key, err)
+			}
+			schemes[strings.TrimPrefix(key, legacySchemeKeyPrefix)] = scheme
+		default:
+			// 其余的 key 应为以名称保存的零售商
+			retailer := new(lib.Retailer)
+			if json.Unmarshal(value, retailer) != nil || retailer.RetailerName != key {
+				res.Skipped = append(res.Skipped, key)
+				continue
+			}
+			retailers[key] = retailer
+		}
+	}
+
+	// 每个零售商都必须指定所有者，否则迁移后无人能以该零售商的身份操作
+	var missing []string
+	for retailerName := range retailers {
+		if owners[retailerName] == "" {
+			missing = append(missing, retailerName)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("the owners of retailers %v are not specified", missing)
+	}
+
+	// 原有的唯一供应商，以执行迁移的身份作为其管理员
+	supplierName := string(legacy[legacyKeyOfSupplier])
+	if supplierName != "" {
+		supplier := &lib.Supplier{
+			SupplierName: supplierName,
+			Admins:       []string{callerID},
+			Quorum:       1,
+			State:        lib.Active,
+		}
+		err = appendAdminChange(stub, supplier, lib.AdminRegister, "", []string{callerID})
+		if err != nil {
+			return err
+		}
+		err = putSupplier(stub, supplier)
+		if err != nil {
+			return err
+		}
+		res.Suppliers++
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	for _, retailer := range retailers {
+		// 零售商绑定到原有的唯一供应商，审核通过的由其负责补货
+		retailer.Owner = owners[retailer.RetailerName]
+		if supplierName != "" {
+			retailer.Suppliers = []string{supplierName}
+			if retailer.State == lib.Pass {
+				retailer.Supplier = supplierName
+				err = putSupplierRetailer(stub, supplierName, retailer.RetailerName)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		// 旧版本每个零售商只有一个补货方案，迁移为序号 1；没有记录生成交易，以迁移交易 ID 加零售商名称作为 ID
+		if scheme, ok := schemes[retailer.RetailerName]; ok {
+			scheme.SchemeID = fmt.Sprintf("%s-%s", stub.GetTxID(), retailer.RetailerName)
+			scheme.Sequence = 1
+			scheme.CreateTime = txTime
+			scheme.RetailerName = retailer.RetailerName
+			scheme.SupplierName = supplierName
+			scheme.TotalPrice = float64(scheme.ReorderQuantity) * scheme.UnitPrice
+			// 旧版本的补货方案没有回应期限，从迁移时起算，未回应的方案到期后过期
+			if isPendingScheme(scheme) && scheme.ResponseDeadline.IsZero() {
+				scheme.ResponseDeadline = txTime.Add(days(float64(retailer.ReviewCycle)))
+			}
+			err = putScheme(stub, scheme)
+			if err != nil {
+				return err
+			}
+			retailer.SchemeSeq = 1
+			res.Schemes++
+		}
+		err = putRetailer(stub, retailer)
+		if err != nil {
+			return err
+		}
+		res.Retailers++
+	}
+	// 找不到零售商的补货方案保留在原来的 key 中：单独保存的保留该 key，
+	// 补货方案map中有这样的方案时保留整个map，避免删除尚未迁移的方案
+	for retailerName := range schemes {
+		if retailers[retailerName] != nil {
+			continue
+		}
+		if _, ok := legacy[legacySchemeKeyPrefix+retailerName]; ok {
+			res.Skipped = append(res.Skipped, legacySchemeKeyPrefix+retailerName)
+		}
+		if _, ok := schemesMap[retailerName]; ok && !utils.ContainsName(res.Skipped, legacyKeyOfSchemesMap) {
+			res.Skipped = append(res.Skipped, legacyKeyOfSchemesMap)
+		}
+	}
+	sort.Strings(res.Skipped)
+
+	// 删除已迁移的旧 key，无法识别的 key 保留原样
+	for _, key := range keys {
+		if utils.ContainsName(res.Skipped, key) {
+			continue
+		}
+		err = stub.DelState(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 零售商对最新的补货方案还价，提出补货数量与交货日期，等待供应商同意或再次还价
+// 参数： 零售商名称 补货数量 交货日期（YYYY-MM-DD）
+// 返回： 空
+func (t *MedicalSystem) retailerCounterScheme(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 3 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" || args[2] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商的注册身份或成员
+	err = checkRetailerMember(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if retailer.State != lib.Pass {
+		return pb.Response{Status: 400, Message: "The retailer failed the audit", Payload: nil}
+	}
+
+	return counterScheme(stub, retailer, lib.PartyRetailer, args[1], args[2])
+}
+
+// 供应商管理员对零售商的还价再次还价，提出补货数量与交货日期，等待零售商同意、否决或再次还价
+// 参数： 供应商名称 零售商名称 补货数量 交货日期（YYYY-MM-DD）
+// 返回： 空
+func (t *MedicalSystem) supplierCounterScheme(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 4 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 4", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" || args[2] == "" || args[3] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailer, resp := getSupplierRetailer(stub, args[0], args[1])
+	if resp != nil {
+		return *resp
+	}
+
+	return counterScheme(stub, retailer, lib.PartySupplier, args[2], args[3])
+}
+
+// 供应商管理员同意零售商的还价，按还价的补货数量与交货日期创建采购订单
+// 参数： 供应商名称 零售商名称
+// 返回： 库存量、未收货数量与创建的采购订单
+func (t *MedicalSystem) supplierAcceptCounter(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailer, resp := getSupplierRetailer(stub, args[0], args[1])
+	if resp != nil {
+		return *resp
+	}
+
+	scheme, err := getLatestScheme(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get scheme error: %s", err), Payload: nil}
+	} else if scheme == nil {
+		return pb.Response{Status: 400, Message: "The scheme does not exist", Payload: nil}
+	}
+	// 超过回应期限的补货方案不能再协商，过期状态由生成新补货方案或 supplierExpireSchemes 写入账本
+	expired, err := schemeExpired(stub, scheme)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
+	}
+	if expired {
+		return pb.Response{Status: 400, Message: "The scheme has expired", Payload: nil}
+	}
+	if !isPendingScheme(scheme) || lastCounterParty(scheme) != lib.PartyRetailer {
+		return pb.Response{Status: 400, Message: "The scheme has no counter-proposal from the retailer", Payload: nil}
+	}
+
+	last := scheme.Negotiation[len(scheme.Negotiation)-1]
+	return acceptScheme(stub, retailer, scheme, last.Quantity, last.DeliveryDate)
+}
+
+// 补货方案是否尚待回应（待回应或协商中），即还能按状态转换表改变回应结果
+func isPendingScheme(scheme *lib.ReplenishmentScheme) bool {
+	return len(lib.SchemeTransitions[scheme.ResponseResults]) > 0
+}
+
+// 最后一次还价的提出方，尚未协商时为空
+func lastCounterParty(scheme *lib.ReplenishmentScheme) string {
+	if n := len(scheme.Negotiation); n > 0 {
+		return scheme.Negotiation[n-1].Party
+	}
+	return ""
+}
+
+// 读取账本，获取由该供应商负责补货的零售商，并验证调用者为该供应商的管理员，失败时返回错误响应
+func getSupplierRetailer(stub shim.ChaincodeStubInterface, supplierName string, retailerName string) (*lib.Retailer, *pb.Response) {
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return nil, resp
+	}
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return nil, &pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return nil, &pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	if retailer.Supplier != supplierName {
+		return nil, &pb.Response{Status: 403, Message: "Permission denied: the retailer is not served by this supplier", Payload: nil}
+	}
+	return retailer, nil
+}
+
+// 在零售商最新的补货方案上记录一轮还价：双方轮流还价，零售商可对待回应的补货方案首先还价
+func counterScheme(stub shim.ChaincodeStubInterface, retailer *lib.Retailer, party string, quantityArg string, dateArg string) pb.Response {
+	quantity, err := strconv.Atoi(quantityArg)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	}
+	if quantity <= 0 {
+		return pb.Response{Status: 400, Message: "The quantity must be positive", Payload: nil}
+	}
+	deliveryDate, err := time.Parse(lib.DateLayout, dateArg)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid delivery date: %s", err), Payload: nil}
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
+	}
+	if deliveryDate.Before(now.Truncate(24 * time.Hour)) {
+		return pb.Response{Status: 400, Message: "The delivery date cannot be in the past", Payload: nil}
+	}
+
+	// 读取账本，获取该零售商最新的补货方案
+	scheme, err := getLatestScheme(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get scheme error: %s", err), Payload: nil}
+	} else if scheme == nil {
+		return pb.Response{Status: 400, Message: "The scheme does not exist", Payload: nil}
+	}
+	// 超过回应期限的补货方案不能再协商，过期状态由生成新补货方案或 supplierExpireSchemes 写入账本
+	expired, err := schemeExpired(stub, scheme)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
+	}
+	if expired {
+		return pb.Response{Status: 400, Message: "The scheme has expired", Payload: nil}
+	}
+	err = checkSchemeTransition(scheme, lib.Negotiating)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Illegal scheme transition: %s", err), Payload: nil}
+	}
+	// 双方轮流还价，供应商只能回应零售商的还价
+	last := lastCounterParty(scheme)
+	if last == party || (party == lib.PartySupplier && last == "") {
+		return pb.Response{Status: 400, Message: "It is not your turn to counter the scheme", Payload: nil}
+	}
+
+	// 还价的补货数量同样不能使库存水平超过存储容量，并按价格折扣表重新定价
+	config, err := getEffectiveConfig(stub, scheme.SupplierName, retailer.RetailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+	}
+	quantity = capQuantity(quantity, retailer.StorageCapacity, retailer.Inventory+retailer.OnOrder, config.RoundingMultiple)
+	if quantity <= 0 {
+		return pb.Response{Status: 400, Message: "The storage capacity of the retailer is full", Payload: nil}
+	}
+	breaks, err := getSupplierPriceBreaks(stub, retailer.RetailerName, scheme.SupplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get price breaks error: %s", err), Payload: nil}
+	}
+	unitPrice := priceFor(breaks, retailer.UnitPrice, quantity)
+
+	_, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	scheme.Negotiation = append(scheme.Negotiation, lib.NegotiationRound{
+		Round:        len(scheme.Negotiation) + 1,
+		Party:        party,
+		Quantity:     quantity,
+		UnitPrice:    unitPrice,
+		TotalPrice:   float64(quantity) * unitPrice,
+		DeliveryDate: deliveryDate.Format(lib.DateLayout),
+		TxID:         stub.GetTxID(),
+		Time:         now,
+		ProposedBy:   callerID,
+	})
+	err = transitionScheme(scheme, lib.Negotiating)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Illegal scheme transition: %s", err), Payload: nil}
+	}
+	// 写入账本
+	err = putScheme(stub, scheme)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put scheme error: %s", err), Payload: nil}
+	}
+
+	// 发出还价事件
+	err = emitEvent(stub, lib.EventSchemeCountered, &lib.Event{
+		RetailerName: retailer.RetailerName,
+		SupplierName: scheme.SupplierName,
+		State:        scheme.ResponseResults,
+		Inventory:    retailer.Inventory,
+		Scheme:       scheme,
+	})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Counter successful", Payload: nil}
+}
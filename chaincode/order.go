@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 供应商管理员将已确认的采购订单发货，发货数量计入零售商的在途数量
+// 参数： 供应商名称 零售商名称 采购订单序号
+// 返回： 采购订单
+func (t *MedicalSystem) supplierShipOrder(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return supplierAdvanceOrder(stub, args, lib.OrderConfirmed, lib.OrderShipped)
+}
+
+// 供应商管理员将已发货的采购订单标记为送达，等待零售商收货
+// 参数： 供应商名称 零售商名称 采购订单序号
+// 返回： 采购订单
+func (t *MedicalSystem) supplierDeliverOrder(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return supplierAdvanceOrder(stub, args, lib.OrderShipped, lib.OrderDelivered)
+}
+
+// 零售商对已送达的采购订单收货，记录到货、短缺与损坏的数量，完好的数量计入库存量
+// 不指定数量时视为全部完好到货；有短缺或损坏时创建索赔，等待供应商确认或提出异议
+// 参数： 零售商名称 采购订单序号 [到货数量 短缺数量 损坏数量]（到货数量 + 短缺数量 = 订货数量，损坏数量不超过到货数量）
+// 返回： 补货前后的库存量与创建的索赔
+func (t *MedicalSystem) retailerReceiveOrder(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 && len(args) != 5 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2 or 5", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	retailer, order, resp := getRetailerOrder(stub, args[0], args[1])
+	if resp != nil {
+		return *resp
+	}
+	received, short, damaged := order.Quantity, 0, 0
+	if len(args) == 5 {
+		quantities := make([]int, 3)
+		for i, arg := range args[2:] {
+			value, err := strconv.Atoi(arg)
+			if err != nil {
+				return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+			}
+			if value < 0 {
+				return pb.Response{Status: 400, Message: "The quantities cannot be negative", Payload: nil}
+			}
+			quantities[i] = value
+		}
+		received, short, damaged = quantities[0], quantities[1], quantities[2]
+		if received+short != order.Quantity {
+			return pb.Response{Status: 400, Message: fmt.Sprintf("The received and short quantities must add up to the ordered quantity %d", order.Quantity), Payload: nil}
+		}
+		if damaged > received {
+			return pb.Response{Status: 400, Message: "The damaged quantity cannot exceed the received quantity", Payload: nil}
+		}
+	}
+	// 验证调用者为该零售商的注册身份或成员
+	err := checkRetailerMember(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if order.State != lib.OrderDelivered {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The order cannot be received in state %s", order.State), Payload: nil}
+	}
+
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
+	}
+	order.State = lib.OrderReceived
+	order.ReceiveTime = now
+	order.ReceivedQuantity = received
+	order.ShortQuantity = short
+	order.DamagedQuantity = damaged
+
+	// 获取旧的库存量
+	oldInventory := retailer.Inventory
+	// 完好的数量计入库存量，整个订单不再计入在途与未收货数量
+	// 短缺与损坏的数量不再补发，由索赔退款，库存水平随之降低，下次生成补货方案时补足
+	good := received - damaged
+	retailer.Inventory += good
+	retailer.ReceivedSinceReport += good
+	retailer.OnOrder -= order.Quantity
+	retailer.InTransit -= order.Quantity
+
+	// 有短缺或损坏时创建索赔
+	var claim *lib.Claim
+	if short > 0 || damaged > 0 {
+		claim = &lib.Claim{
+			ClaimID:         stub.GetTxID(),
+			Sequence:        order.Sequence,
+			OrderID:         order.OrderID,
+			SchemeID:        order.SchemeID,
+			RetailerName:    order.RetailerName,
+			SupplierName:    order.SupplierName,
+			OrderedQuantity: order.Quantity,
+			ShortQuantity:   short,
+			DamagedQuantity: damaged,
+			ClaimValue:      float64(short+damaged) * order.UnitPrice,
+			State:           lib.ClaimOpen,
+			CreateTime:      now,
+		}
+		err = putClaim(stub, claim)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Put claim error: %s", err), Payload: nil}
+		}
+	}
+
+	resp = putOrderUpdate(stub, retailer, order, claim)
+	if resp != nil {
+		return *resp
+	}
+
+	// 使用匿名结构体存储要返回的内容
+	res := struct {
+		OldInventory int        // 补货前库存量
+		NewInventory int        // 补货后库存量
+		Claim        *lib.Claim // 收货差异产生的索赔
+	}{oldInventory, retailer.Inventory, claim}
+	// 序列化返回值
+	resJSON, err := json.Marshal(res)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Receive successful", Payload: resJSON}
+}
+
+// 查看零售商的全部采购订单
+// 参数： 零售商名称
+// 返回： 采购订单列表
+func (t *MedicalSystem) viewOrders(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商或其供应商的管理员
+	err = checkSchemeViewer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	ordersList, err := listOrders(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List orders error: %s", err), Payload: nil}
+	}
+	// 序列化采购订单列表
+	ordersListJSON, err := json.Marshal(ordersList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: ordersListJSON}
+}
+
+// 根据同意的补货方案创建采购订单，订货数量计入零售商的未收货数量，调用者需将零售商写入账本
+func createOrder(stub shim.ChaincodeStubInterface, retailer *lib.Retailer, scheme *lib.ReplenishmentScheme, quantity int) (*lib.PurchaseOrder, error) {
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	// 订货数量可能因协商、配给或存储容量与补货方案不同，按价格折扣表对订货数量定价
+	breaks, err := getSupplierPriceBreaks(stub, retailer.RetailerName, scheme.SupplierName)
+	if err != nil {
+		return nil, err
+	}
+	unitPrice := priceFor(breaks, retailer.UnitPrice, quantity)
+	order := &lib.PurchaseOrder{
+		OrderID:      stub.GetTxID(),
+		Sequence:     scheme.Sequence,
+		SchemeID:     scheme.SchemeID,
+		RetailerName: scheme.RetailerName,
+		SupplierName: scheme.SupplierName,
+		Quantity:     quantity,
+		UnitPrice:    unitPrice,
+		TotalPrice:   float64(quantity) * unitPrice,
+		DeliveryDate: scheme.DeliveryDate,
+		State:        lib.OrderConfirmed,
+		ConfirmTime:  now,
+	}
+	err = putOrder(stub, order)
+	if err != nil {
+		return nil, err
+	}
+	retailer.OnOrder += quantity
+	return order, nil
+}
+
+// 供应商推进采购订单的状态：验证调用者为负责该订单的供应商管理员，订单处于 from 状态时改为 to 状态
+func supplierAdvanceOrder(stub shim.ChaincodeStubInterface, args []string, from string, to string) pb.Response {
+	// 检查参数个数
+	if len(args) != 3 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" || args[2] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	retailer, order, resp := getRetailerOrder(stub, args[1], args[2])
+	if resp != nil {
+		return *resp
+	}
+	if order.SupplierName != supplierName {
+		return pb.Response{Status: 403, Message: "Permission denied: the order is not served by this supplier", Payload: nil}
+	}
+	if order.State != from {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The order cannot be changed to %s in state %s", to, order.State), Payload: nil}
+	}
+
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
+	}
+	order.State = to
+	var message string
+	switch to {
+	case lib.OrderShipped:
+		order.ShipTime = now
+		retailer.InTransit += order.Quantity
+		message = "Ship successful"
+	case lib.OrderDelivered:
+		order.DeliverTime = now
+		message = "Deliver successful"
+	}
+
+	resp = putOrderUpdate(stub, retailer, order, nil)
+	if resp != nil {
+		return *resp
+	}
+	// 序列化采购订单
+	orderJSON, err := json.Marshal(order)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: message, Payload: orderJSON}
+}
+
+// 读取账本，获取零售商及其指定序号的采购订单，失败时返回错误响应
+func getRetailerOrder(stub shim.ChaincodeStubInterface, retailerName string, sequenceArg string) (*lib.Retailer, *lib.PurchaseOrder, *pb.Response) {
+	sequence, err := strconv.Atoi(sequenceArg)
+	if err != nil {
+		return nil, nil, &pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	}
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return nil, nil, &pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return nil, nil, &pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	order, err := getOrder(stub, retailerName, sequence)
+	if err != nil {
+		return nil, nil, &pb.Response{Status: 500, Message: fmt.Sprintf("Get order error: %s", err), Payload: nil}
+	} else if order == nil {
+		return nil, nil, &pb.Response{Status: 400, Message: "The order does not exist", Payload: nil}
+	}
+	return retailer, order, nil
+}
+
+// 将状态变化的采购订单与零售商写入账本，并发出采购订单事件（附带收货差异产生的索赔），失败时返回错误响应
+func putOrderUpdate(stub shim.ChaincodeStubInterface, retailer *lib.Retailer, order *lib.PurchaseOrder, claim *lib.Claim) *pb.Response {
+	err := putOrder(stub, order)
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Put order error: %s", err), Payload: nil}
+	}
+	err = putRetailer(stub, retailer)
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
+	}
+	err = emitEvent(stub, lib.EventOrderUpdated, &lib.Event{
+		RetailerName: retailer.RetailerName,
+		SupplierName: order.SupplierName,
+		State:        order.State,
+		Inventory:    retailer.Inventory,
+		Order:        order,
+		Claim:        claim,
+	})
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Set event error: %s", err), Payload: nil}
+	}
+	return nil
+}
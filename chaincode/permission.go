@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 供应商管理员或通道管理员修改函数的角色权限，权限表对所有供应商生效
+// 参数： 函数名称 角色列表（以逗号分隔，* 表示不限制角色） [供应商名称]
+// 指定供应商名称时调用者须为该启用供应商的管理员，不指定时须为通道管理员
+// 返回： 空
+func (t *MedicalSystem) setPermission(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 && len(args) != 3 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2 or 3", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	function := args[0]
+	roles := utils.SplitNames(args[1])
+	if len(roles) == 0 {
+		return pb.Response{Status: 400, Message: "At least one role is required", Payload: nil}
+	}
+	// 只能修改链码中存在的函数；权限管理函数本身不可修改，避免管理员被锁在外面
+	if _, ok := lib.DefaultPermissions[function]; !ok {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Unknown function %s", function), Payload: nil}
+	}
+	if function == "setPermission" {
+		return pb.Response{Status: 400, Message: "The permission of setPermission cannot be changed", Payload: nil}
+	}
+
+	// 只有供应商管理员或通道管理员可以修改权限表
+	if len(args) == 3 {
+		supplier, err := getSupplier(stub, args[2])
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+		} else if supplier == nil {
+			return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+		}
+		err = checkSupplierAdmin(stub, supplier)
+		if err != nil {
+			return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+		}
+		if supplier.State != lib.Active {
+			return pb.Response{Status: 400, Message: "The supplier has been deactivated", Payload: nil}
+		}
+	} else {
+		err := checkChannelAdmin(stub)
+		if err != nil {
+			return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+		}
+	}
+
+	// 更新权限表
+	// 账本上只保存修改过的函数
+	overrides := make(map[string][]string)
+	_, err := getConfig(stub, lib.ConfigPermissions, &overrides)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get permissions error: %s", err), Payload: nil}
+	}
+	overrides[function] = roles
+	err = putConfig(stub, lib.ConfigPermissions, overrides)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put permissions error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Set permission successful", Payload: nil}
+}
+
+// 查看权限表
+// 参数： 空
+// 返回： 函数名称到允许角色列表的map（默认权限与账本上的修改合并后的结果）
+func (t *MedicalSystem) viewPermissions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 0 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 0", Payload: nil}
+	}
+
+	permissions, err := getPermissions(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get permissions error: %s", err), Payload: nil}
+	}
+	permissionsJSON, err := json.Marshal(permissions)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: permissionsJSON}
+}
+
+// 读取权限表：账本上修改过的函数使用账本中的角色，其余使用默认权限
+func getPermissions(stub shim.ChaincodeStubInterface) (map[string][]string, error) {
+	permissions := make(map[string][]string)
+	for function, roles := range lib.DefaultPermissions {
+		permissions[function] = roles
+	}
+
+	overrides := make(map[string][]string)
+	_, err := getConfig(stub, lib.ConfigPermissions, &overrides)
+	if err != nil {
+		return nil, err
+	}
+	for function, roles := range overrides {
+		// 忽略默认权限表中已不存在的函数
+		if _, ok := permissions[function]; ok {
+			permissions[function] = roles
+		}
+	}
+	return permissions, nil
+}
+
+// 验证调用者证书的 role 属性包含该函数允许的角色之一，权限表中没有的函数拒绝调用
+func checkPermission(stub shim.ChaincodeStubInterface, function string) error {
+	permissions, err := getPermissions(stub)
+	if err != nil {
+		return err
+	}
+	roles, ok := permissions[function]
+	if !ok {
+		return fmt.Errorf("%s is not a permitted function", function)
+	}
+	if utils.ContainsName(roles, lib.AnyRole) {
+		return nil
+	}
+
+	value, found, err := cid.GetAttributeValue(stub, lib.AttrRole)
+	if err != nil {
+		return err
+	}
+	if found {
+		for _, role := range utils.SplitNames(value) {
+			if utils.ContainsName(roles, role) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s requires one of the roles %v", function, roles)
+}
+
+// 判断调用者证书的 role 属性是否包含指定角色
+func hasRole(stub shim.ChaincodeStubInterface, role string) (bool, error) {
+	value, found, err := cid.GetAttributeValue(stub, lib.AttrRole)
+	if err != nil || !found {
+		return false, err
+	}
+	return utils.ContainsName(utils.SplitNames(value), role), nil
+}
+
+// 将调用者加入通道管理员列表，已在列表中时不修改
+func addChannelAdmin(stub shim.ChaincodeStubInterface) error {
+	_, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	var admins []string
+	_, err = getConfig(stub, lib.ConfigChannelAdmins, &admins)
+	if err != nil {
+		return err
+	}
+	if utils.ContainsName(admins, callerID) {
+		return nil
+	}
+	return putConfig(stub, lib.ConfigChannelAdmins, append(admins, callerID))
+}
+
+// 验证调用者为通道管理员（实例化或升级过链码的身份）
+func checkChannelAdmin(stub shim.ChaincodeStubInterface) error {
+	_, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	var admins []string
+	_, err = getConfig(stub, lib.ConfigChannelAdmins, &admins)
+	if err != nil {
+		return err
+	}
+	if !utils.ContainsName(admins, callerID) {
+		return fmt.Errorf("the caller is not a channel admin")
+	}
+	return nil
+}
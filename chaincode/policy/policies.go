@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+// (s,S)：库存低于订购点 s 时补货到最大库存 S
+type sS struct{}
+
+func (sS) Name() string                             { return NameSS }
+func (sS) Parameters() []string                     { return []string{"s", "S"} }
+func (sS) Check(profile *lib.RetailerProfile) error { return nil }
+
+func (sS) Calculate(in *Input, params map[string]float64) *Result {
+	s := param(params, "s", reorderPoint(in))
+	S := param(params, "S", orderUpTo(in))
+	res := newResult(in, map[string]float64{"s": s, "S": S})
+	if float64(in.Inventory) < s {
+		res.Quantity = fillTo(S, in.Inventory)
+	}
+	return res
+}
+
+// (R,S)：每个审查周期 R 都补货到最大库存 S
+type rS struct{}
+
+func (rS) Name() string                             { return NameRS }
+func (rS) Parameters() []string                     { return []string{"S"} }
+func (rS) Check(profile *lib.RetailerProfile) error { return nil }
+
+func (rS) Calculate(in *Input, params map[string]float64) *Result {
+	S := param(params, "S", orderUpTo(in))
+	res := newResult(in, map[string]float64{"R": float64(in.Profile.ReviewCycle), "S": S})
+	if float64(in.Inventory) < S {
+		res.Quantity = fillTo(S, in.Inventory)
+	}
+	return res
+}
+
+// (s,Q)：库存低于订购点 s 时订购固定数量 Q，Q 默认为一个审查周期的需求量
+type sQ struct{}
+
+func (sQ) Name() string                             { return NameSQ }
+func (sQ) Parameters() []string                     { return []string{"s", "Q"} }
+func (sQ) Check(profile *lib.RetailerProfile) error { return nil }
+
+func (sQ) Calculate(in *Input, params map[string]float64) *Result {
+	s := param(params, "s", reorderPoint(in))
+	Q := param(params, "Q", float64(in.Profile.ReviewCycle*in.Profile.AverageDemand))
+	res := newResult(in, map[string]float64{"s": s, "Q": Q})
+	if float64(in.Inventory) < s {
+		res.Quantity = Q
+	}
+	return res
+}
+
+// min-max：库存不高于 min 时补货到 max
+type minMax struct{}
+
+func (minMax) Name() string                             { return NameMinMax }
+func (minMax) Parameters() []string                     { return []string{"min", "max"} }
+func (minMax) Check(profile *lib.RetailerProfile) error { return nil }
+
+func (minMax) Calculate(in *Input, params map[string]float64) *Result {
+	min := param(params, "min", reorderPoint(in))
+	max := param(params, "max", orderUpTo(in))
+	res := newResult(in, map[string]float64{"min": min, "max": max})
+	if float64(in.Inventory) <= min && float64(in.Inventory) < max {
+		res.Quantity = fillTo(max, in.Inventory)
+	}
+	return res
+}
+
+// 经济订货批量：库存低于订购点 s 时订购 EOQ = sqrt(2DK/h)
+// D 为年需求量，K 为每次订货的固定成本，h 为单位商品年持有成本（年利率 x 订货单价）
+type eoq struct{}
+
+func (eoq) Name() string         { return NameEOQ }
+func (eoq) Parameters() []string { return []string{"s"} }
+
+func (eoq) Check(profile *lib.RetailerProfile) error {
+	if profile.FixedOrderCost <= 0 || profile.AnnualInterestRate <= 0 || profile.UnitPrice <= 0 {
+		return fmt.Errorf("policy %s requires positive fixed_order_cost, annual_interest_rate and unit_price", NameEOQ)
+	}
+	return nil
+}
+
+func (eoq) Calculate(in *Input, params map[string]float64) *Result {
+	s := param(params, "s", reorderPoint(in))
+	breakdown := EOQ(&in.Profile)
+	res := newResult(in, map[string]float64{"s": s, "Q": breakdown.EconomicQuantity})
+	res.CostBreakdown = breakdown
+	if float64(in.Inventory) < s {
+		res.Quantity = breakdown.EconomicQuantity
+	}
+	return res
+}
+
+// EOQ 计算经济订货批量及对应的年度成本
+func EOQ(profile *lib.RetailerProfile) *lib.CostBreakdown {
+	d := float64(profile.AverageDemand * lib.DaysPerYear)
+	k := profile.FixedOrderCost
+	h := profile.AnnualInterestRate / 100 * profile.UnitPrice
+	breakdown := &lib.CostBreakdown{AnnualDemand: d, OrderCost: k, HoldingCost: h}
+	if d == 0 || h == 0 {
+		return breakdown
+	}
+	q := math.Sqrt(2 * d * k / h)
+	breakdown.EconomicQuantity = q
+	breakdown.OrdersPerYear = d / q
+	breakdown.AnnualOrderingCost = d / q * k
+	breakdown.AnnualHoldingCost = q / 2 * h
+	breakdown.TotalAnnualCost = breakdown.AnnualOrderingCost + breakdown.AnnualHoldingCost
+	return breakdown
+}
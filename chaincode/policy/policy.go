@@ -0,0 +1,196 @@
+// Package policy 补货策略，根据零售商的补货参数与当前库存计算补货数量
+package policy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+// 策略名称
+const (
+	NameSS     = "sS"     // (s,S)：库存低于订购点 s 时补货到最大库存 S
+	NameRS     = "RS"     // (R,S)：每个审查周期都补货到最大库存 S
+	NameSQ     = "sQ"     // (s,Q)：库存低于订购点 s 时订购固定数量 Q
+	NameMinMax = "minmax" // min-max：库存不高于 min 时补货到 max
+	NameEOQ    = "EOQ"    // 经济订货批量：库存低于订购点 s 时订购 EOQ
+)
+
+// Input 计算补货数量所需的数据
+type Input struct {
+	Profile       lib.RetailerProfile // 零售商的补货参数
+	Inventory     int                 // 当前库存量
+	ResidualValue int                 // 残值
+}
+
+// Result 补货数量的计算结果
+type Result struct {
+	Quantity      float64            // 补货数量，未取整，由调用者按取整规则取整
+	Parameters    map[string]float64 // 实际使用的策略参数（未设置的参数取由补货参数推算的默认值）
+	CostBreakdown *lib.CostBreakdown // 经济订货批量的成本构成，其他策略为 nil
+}
+
+// Policy 补货策略
+type Policy interface {
+	// Name 策略名称
+	Name() string
+	// Parameters 可在审核时约定的参数名称
+	Parameters() []string
+	// Check 验证补货参数能否使用该策略
+	Check(profile *lib.RetailerProfile) error
+	// Calculate 计算补货数量，params 中未设置的参数使用默认值
+	Calculate(in *Input, params map[string]float64) *Result
+}
+
+var policies = map[string]Policy{
+	NameSS:     sS{},
+	NameRS:     rS{},
+	NameSQ:     sQ{},
+	NameMinMax: minMax{},
+	NameEOQ:    eoq{},
+}
+
+// Get 通过名称获取补货策略
+func Get(name string) (Policy, error) {
+	p, ok := policies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown replenishment policy %s, expecting one of %v", name, Names())
+	}
+	return p, nil
+}
+
+// Names 所有补货策略的名称
+func Names() []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate 验证策略名称与参数，以及补货参数能否使用该策略
+func Validate(name string, params map[string]float64, profile *lib.RetailerProfile) error {
+	err := ValidateParams(name, params)
+	if err != nil {
+		return err
+	}
+	p, _ := Get(name)
+	return p.Check(profile)
+}
+
+// ValidateParams 验证策略名称与参数，参数必须是该策略可设置的参数且不能为负数
+func ValidateParams(name string, params map[string]float64) error {
+	p, err := Get(name)
+	if err != nil {
+		return err
+	}
+	for key, value := range params {
+		if !contains(p.Parameters(), key) {
+			return fmt.Errorf("policy %s has no parameter %s, expecting %v", name, key, p.Parameters())
+		}
+		if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("parameter %s must be a non-negative number", key)
+		}
+	}
+	return nil
+}
+
+// CheckBounds 验证 (s,S) 的订购点 s 不高于最大库存 S、min-max 的 min 不高于 max，未设置的参数取由补货参数推算的默认值
+func CheckBounds(name string, params map[string]float64, in *Input) error {
+	var low, high string
+	switch name {
+	case NameSS:
+		low, high = "s", "S"
+	case NameMinMax:
+		low, high = "min", "max"
+	default:
+		return nil
+	}
+	l := param(params, low, reorderPoint(in))
+	h := param(params, high, orderUpTo(in))
+	if l > h {
+		return fmt.Errorf("parameter %s (%g) cannot be greater than %s (%g)", low, l, high, h)
+	}
+	return nil
+}
+
+// 订购点默认值：提前期 x 需求量均值 + 残值 + 安全库存
+func reorderPoint(in *Input) float64 {
+	return float64(in.Profile.LeadTime*in.Profile.AverageDemand+in.ResidualValue) + SafetyStock(&in.Profile)
+}
+
+// 最大库存默认值：(审查周期 + 提前期) x 需求量均值 + 残值 + 安全库存
+func orderUpTo(in *Input) float64 {
+	return float64((in.Profile.ReviewCycle+in.Profile.LeadTime)*in.Profile.AverageDemand+in.ResidualValue) + SafetyStock(&in.Profile)
+}
+
+// ServiceLevelZ 目标周期服务水平 p（百分比）对应的标准正态分位数 z = sqrt(2) x erfinv(2p - 1)
+func ServiceLevelZ(serviceLevel float64) float64 {
+	if serviceLevel <= 0 {
+		return 0
+	}
+	return math.Sqrt2 * math.Erfinv(2*serviceLevel/100-1)
+}
+
+// LeadTimeDemandStdDev 提前期内需求量的标准差 sqrt(L x σd² + d² x σL²)
+// L、σL 为提前期的均值与标准差，d、σd 为需求量的均值与标准差，提前期固定时为 σd x sqrt(L)
+func LeadTimeDemandStdDev(profile *lib.RetailerProfile) float64 {
+	l := float64(profile.LeadTime)
+	d := float64(profile.AverageDemand)
+	return math.Sqrt(l*profile.DemandStdDev*profile.DemandStdDev + d*d*profile.LeadTimeStdDev*profile.LeadTimeStdDev)
+}
+
+// SafetyStock 安全库存 = z x 提前期内需求量的标准差，未设置服务水平时为 0
+func SafetyStock(profile *lib.RetailerProfile) float64 {
+	return ServiceLevelZ(profile.ServiceLevel) * LeadTimeDemandStdDev(profile)
+}
+
+// 创建计算结果，记录安全库存及其 z 值
+func newResult(in *Input, params map[string]float64) *Result {
+	params["z"] = ServiceLevelZ(in.Profile.ServiceLevel)
+	params["lead_time_demand_std_dev"] = LeadTimeDemandStdDev(&in.Profile)
+	params["safety_stock"] = SafetyStock(&in.Profile)
+	return &Result{Parameters: params}
+}
+
+// 取参数值，未设置时使用默认值
+func param(params map[string]float64, name string, def float64) float64 {
+	if value, ok := params[name]; ok {
+		return value
+	}
+	return def
+}
+
+// 补货到 level 所需的数量，库存已达到 level 时为 0
+func fillTo(level float64, inventory int) float64 {
+	return math.Max(level-float64(inventory), 0)
+}
+
+// Round 按取整规则将补货数量取整为 multiple 的整数倍
+func Round(quantity float64, rule string, multiple int) int {
+	if multiple < 1 {
+		multiple = 1
+	}
+	units := quantity / float64(multiple)
+	switch rule {
+	case lib.RoundDown:
+		units = math.Floor(units)
+	case lib.RoundNearest:
+		units = math.Floor(units + 0.5)
+	default:
+		units = math.Ceil(units)
+	}
+	return int(units) * multiple
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
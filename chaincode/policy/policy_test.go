@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestEOQ(t *testing.T) {
+	// D = 10 x 365 = 3650，K = 50，h = 20% x 5 = 1
+	profile := &lib.RetailerProfile{AverageDemand: 10, FixedOrderCost: 50, AnnualInterestRate: 20, UnitPrice: 5, InventoryValue: 1000}
+	breakdown := EOQ(profile)
+	if !almostEqual(breakdown.HoldingCost, 1) {
+		t.Fatalf("holding cost = %v, want 1", breakdown.HoldingCost)
+	}
+	want := math.Sqrt(2 * 3650 * 50 / 1.0)
+	if !almostEqual(breakdown.EconomicQuantity, want) {
+		t.Fatalf("EOQ = %v, want %v", breakdown.EconomicQuantity, want)
+	}
+	// 经济订货批量处年订货成本等于年持有成本
+	if !almostEqual(breakdown.AnnualOrderingCost, breakdown.AnnualHoldingCost) {
+		t.Fatalf("ordering cost %v != holding cost %v", breakdown.AnnualOrderingCost, breakdown.AnnualHoldingCost)
+	}
+	if !almostEqual(breakdown.TotalAnnualCost, breakdown.AnnualOrderingCost+breakdown.AnnualHoldingCost) {
+		t.Fatalf("total cost = %v", breakdown.TotalAnnualCost)
+	}
+}
+
+func TestEOQWithoutHoldingCost(t *testing.T) {
+	breakdown := EOQ(&lib.RetailerProfile{AverageDemand: 10, FixedOrderCost: 50, AnnualInterestRate: 20})
+	if breakdown.EconomicQuantity != 0 {
+		t.Fatalf("EOQ = %v, want 0 without unit price", breakdown.EconomicQuantity)
+	}
+	if err := (eoq{}).Check(&lib.RetailerProfile{FixedOrderCost: 50, AnnualInterestRate: 20, InventoryValue: 1000}); err == nil {
+		t.Fatal("EOQ check should require a unit price")
+	}
+}
+
+func TestCheckBounds(t *testing.T) {
+	in := &Input{Profile: lib.RetailerProfile{LeadTime: 2, AverageDemand: 5, ReviewCycle: 3}}
+	cases := []struct {
+		name   string
+		params map[string]float64
+		ok     bool
+	}{
+		{NameSS, map[string]float64{"s": 10, "S": 10}, true},
+		{NameSS, map[string]float64{"s": 11, "S": 10}, false},
+		// 未设置的 S 取默认值 (3 + 2) x 5 = 25
+		{NameSS, map[string]float64{"s": 30}, false},
+		{NameMinMax, map[string]float64{"min": 5, "max": 20}, true},
+		{NameMinMax, map[string]float64{"min": 21, "max": 20}, false},
+		{NameSQ, map[string]float64{"s": 100, "Q": 1}, true},
+	}
+	for _, c := range cases {
+		err := CheckBounds(c.name, c.params, in)
+		if (err == nil) != c.ok {
+			t.Errorf("CheckBounds(%s, %v) = %v, want ok %v", c.name, c.params, err, c.ok)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	in := &Input{Profile: lib.RetailerProfile{LeadTime: 2, AverageDemand: 5, ReviewCycle: 3}, Inventory: 8, ResidualValue: 1}
+	cases := []struct {
+		name   string
+		params map[string]float64
+		want   float64
+	}{
+		// 订购点 2 x 5 + 1 = 11，最大库存 (3 + 2) x 5 + 1 = 26
+		{NameSS, map[string]float64{}, 18},
+		{NameSS, map[string]float64{"s": 8}, 0},
+		{NameRS, map[string]float64{"S": 20}, 12},
+		{NameSQ, map[string]float64{"Q": 40}, 40},
+		{NameMinMax, map[string]float64{"min": 8, "max": 30}, 22},
+		{NameMinMax, map[string]float64{"min": 7}, 0},
+	}
+	for _, c := range cases {
+		p, err := Get(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Calculate(in, c.params).Quantity; !almostEqual(got, c.want) {
+			t.Errorf("%s%v quantity = %v, want %v", c.name, c.params, got, c.want)
+		}
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	if err := ValidateParams(NameSS, map[string]float64{"s": 5, "S": 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateParams(NameSS, map[string]float64{"Q": 5}); err == nil {
+		t.Fatal("unknown parameter should be rejected")
+	}
+	if err := ValidateParams(NameMinMax, map[string]float64{"min": -1}); err == nil {
+		t.Fatal("negative parameter should be rejected")
+	}
+	if err := ValidateParams("unknown", nil); err == nil {
+		t.Fatal("unknown policy should be rejected")
+	}
+}
+
+func TestServiceLevelZ(t *testing.T) {
+	cases := []struct {
+		level float64
+		want  float64
+	}{
+		{0, 0},
+		{50, 0},
+		{95, 1.644854},
+		{97.5, 1.959964},
+	}
+	for _, c := range cases {
+		if got := ServiceLevelZ(c.level); math.Abs(got-c.want) > 1e-5 {
+			t.Errorf("ServiceLevelZ(%v) = %v, want %v", c.level, got, c.want)
+		}
+	}
+}
+
+func TestSafetyStock(t *testing.T) {
+	// 提前期固定时为 z x σd x sqrt(L)
+	profile := &lib.RetailerProfile{LeadTime: 4, AverageDemand: 10, DemandStdDev: 3, ServiceLevel: 95}
+	want := ServiceLevelZ(95) * 3 * 2
+	if got := SafetyStock(profile); !almostEqual(got, want) {
+		t.Fatalf("SafetyStock = %v, want %v", got, want)
+	}
+	// 未设置服务水平时不设安全库存
+	profile.ServiceLevel = 0
+	if got := SafetyStock(profile); got != 0 {
+		t.Fatalf("SafetyStock = %v, want 0", got)
+	}
+}
+
+func TestSafetyStockLeadTimeVariability(t *testing.T) {
+	// 提前期波动时为 z x sqrt(L x σd² + d² x σL²)
+	profile := &lib.RetailerProfile{LeadTime: 4, AverageDemand: 10, DemandStdDev: 3, ServiceLevel: 95, LeadTimeStdDev: 1}
+	if got, want := LeadTimeDemandStdDev(profile), math.Sqrt(4*9+100*1); !almostEqual(got, want) {
+		t.Fatalf("LeadTimeDemandStdDev = %v, want %v", got, want)
+	}
+	want := ServiceLevelZ(95) * math.Sqrt(4*9+100*1)
+	if got := SafetyStock(profile); !almostEqual(got, want) {
+		t.Fatalf("SafetyStock = %v, want %v", got, want)
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		quantity float64
+		rule     string
+		multiple int
+		want     int
+	}{
+		{12.1, lib.RoundUp, 1, 13},
+		{12.9, lib.RoundDown, 1, 12},
+		{12.5, lib.RoundNearest, 1, 13},
+		{12, lib.RoundUp, 5, 15},
+		{12, lib.RoundDown, 5, 10},
+		{12, lib.RoundNearest, 5, 10},
+		{13, lib.RoundNearest, 5, 15},
+		{7, lib.RoundUp, 0, 7},
+	}
+	for _, c := range cases {
+		if got := Round(c.quantity, c.rule, c.multiple); got != c.want {
+			t.Errorf("Round(%v, %s, %d) = %d, want %d", c.quantity, c.rule, c.multiple, got, c.want)
+		}
+	}
+}
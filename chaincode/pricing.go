@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/policy"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 供应商管理员设置零售商的价格折扣表，[] 表示取消折扣
+// 参数： 供应商名称 零售商名称 折扣档位JSON（如 [{"min_quantity":500,"unit_price":4.5}]）
+// 返回： 空
+func (t *MedicalSystem) supplierSetPriceBreaks(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 3 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" || args[2] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+	retailerName := args[1]
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	// 只能设置由该供应商负责补货的零售商
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	if retailer.Supplier != supplierName {
+		return pb.Response{Status: 403, Message: "Permission denied: the retailer is not served by this supplier", Payload: nil}
+	}
+
+	// 解析并验证折扣档位
+	table := &lib.PriceBreakTable{RetailerName: retailerName, SupplierName: supplierName}
+	err = json.Unmarshal([]byte(args[2]), &table.Breaks)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid price breaks: %s", err), Payload: nil}
+	}
+	err = checkPriceBreaks(table.Breaks)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid price breaks: %s", err), Payload: nil}
+	}
+
+	// 写入账本，并记录修改者身份
+	table.ModifiedMSP, table.ModifiedBy, err = utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	key, err := utils.ConstructPriceBreaksKey(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Construct key error: %s", err), Payload: nil}
+	}
+	err = putStateJSON(stub, key, table)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put price breaks error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Set price breaks successful", Payload: nil}
+}
+
+// 查看零售商的价格折扣表
+// 参数： 零售商名称
+// 返回： 价格折扣表
+func (t *MedicalSystem) viewPriceBreaks(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商或其供应商的管理员
+	err = checkSchemeViewer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	table, err := getPriceBreaks(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get price breaks error: %s", err), Payload: nil}
+	} else if table == nil {
+		table = &lib.PriceBreakTable{RetailerName: retailerName, Breaks: []lib.PriceBreak{}}
+	}
+	// 序列化对象
+	tableJSON, err := json.Marshal(table)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: tableJSON}
+}
+
+// 读取账本，获取零售商的价格折扣表，不存在时返回 nil
+func getPriceBreaks(stub shim.ChaincodeStubInterface, retailerName string) (*lib.PriceBreakTable, error) {
+	key, err := utils.ConstructPriceBreaksKey(stub, retailerName)
+	if err != nil {
+		return nil, err
+	}
+	table := new(lib.PriceBreakTable)
+	found, err := getStateJSON(stub, key, table)
+	if err != nil || !found {
+		return nil, err
+	}
+	return table, nil
+}
+
+// 读取账本，获取零售商在该供应商处适用的价格折扣档位，没有该供应商设置的折扣表时返回 nil
+func getSupplierPriceBreaks(stub shim.ChaincodeStubInterface, retailerName string, supplierName string) ([]lib.PriceBreak, error) {
+	table, err := getPriceBreaks(stub, retailerName)
+	if err != nil || table == nil || table.SupplierName != supplierName {
+		return nil, err
+	}
+	return table.Breaks, nil
+}
+
+// 验证折扣档位：起订数量与单价为正数，起订数量不重复，并按起订数量升序排列
+func checkPriceBreaks(breaks []lib.PriceBreak) error {
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].MinQuantity < breaks[j].MinQuantity })
+	for i, b := range breaks {
+		if b.MinQuantity <= 0 || b.UnitPrice <= 0 {
+			return fmt.Errorf("min_quantity and unit_price must be positive")
+		}
+		if i > 0 && b.MinQuantity == breaks[i-1].MinQuantity {
+			return fmt.Errorf("duplicate min_quantity %d", b.MinQuantity)
+		}
+	}
+	return nil
+}
+
+// 订货数量对应的单价：不超过订货数量的最高档位的单价，没有适用档位时为 basePrice
+func priceFor(breaks []lib.PriceBreak, basePrice float64, quantity int) float64 {
+	price := basePrice
+	for _, b := range breaks {
+		if quantity >= b.MinQuantity {
+			price = b.UnitPrice
+		}
+	}
+	return price
+}
+
+// 按价格折扣表为补货方案定价，当增加订货到更高档位（按取整倍数向上取整）的总价更低时增加订货数量
+func priceScheme(scheme *lib.ReplenishmentScheme, breaks []lib.PriceBreak, basePrice float64, multiple int) {
+	scheme.PolicyQuantity = scheme.ReorderQuantity
+	scheme.UnitPrice = priceFor(breaks, basePrice, scheme.ReorderQuantity)
+	scheme.TotalPrice = float64(scheme.ReorderQuantity) * scheme.UnitPrice
+	// 无需补货时不增加订货
+	if scheme.ReorderQuantity == 0 {
+		return
+	}
+	for _, b := range breaks {
+		if b.MinQuantity <= scheme.PolicyQuantity {
+			continue
+		}
+		quantity := policy.Round(float64(b.MinQuantity), lib.RoundUp, multiple)
+		unitPrice := priceFor(breaks, basePrice, quantity)
+		totalPrice := float64(quantity) * unitPrice
+		if totalPrice < scheme.TotalPrice {
+			scheme.ReorderQuantity = quantity
+			scheme.UnitPrice = unitPrice
+			scheme.TotalPrice = totalPrice
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+var testBreaks = []lib.PriceBreak{
+	{MinQuantity: 100, UnitPrice: 4.5},
+	{MinQuantity: 500, UnitPrice: 4},
+}
+
+func TestPriceFor(t *testing.T) {
+	cases := []struct {
+		quantity int
+		want     float64
+	}{
+		{0, 5},
+		{99, 5},
+		{100, 4.5},
+		{499, 4.5},
+		{500, 4},
+		{1000, 4},
+	}
+	for _, c := range cases {
+		if got := priceFor(testBreaks, 5, c.quantity); got != c.want {
+			t.Errorf("priceFor(%d) = %v, want %v", c.quantity, got, c.want)
+		}
+	}
+	if got := priceFor(nil, 5, 1000); got != 5 {
+		t.Errorf("priceFor without breaks = %v, want 5", got)
+	}
+}
+
+func TestPriceScheme(t *testing.T) {
+	// 90 x 5 = 450 高于 100 x 4.5 = 450 不成立，保持原数量
+	scheme := &lib.ReplenishmentScheme{ReorderQuantity: 90}
+	priceScheme(scheme, testBreaks, 5, 1)
+	if scheme.ReorderQuantity != 90 || scheme.UnitPrice != 5 || scheme.TotalPrice != 450 {
+		t.Fatalf("scheme = %d x %v = %v, want 90 x 5 = 450", scheme.ReorderQuantity, scheme.UnitPrice, scheme.TotalPrice)
+	}
+
+	// 95 x 5 = 475 高于 100 x 4.5 = 450，增加订货到下一档位
+	scheme = &lib.ReplenishmentScheme{ReorderQuantity: 95}
+	priceScheme(scheme, testBreaks, 5, 1)
+	if scheme.ReorderQuantity != 100 || scheme.UnitPrice != 4.5 || scheme.TotalPrice != 450 {
+		t.Fatalf("scheme = %d x %v = %v, want 100 x 4.5 = 450", scheme.ReorderQuantity, scheme.UnitPrice, scheme.TotalPrice)
+	}
+	if scheme.PolicyQuantity != 95 {
+		t.Fatalf("policy quantity = %d, want 95", scheme.PolicyQuantity)
+	}
+
+	// 无需补货时不增加订货
+	scheme = &lib.ReplenishmentScheme{}
+	priceScheme(scheme, testBreaks, 5, 1)
+	if scheme.ReorderQuantity != 0 || scheme.TotalPrice != 0 {
+		t.Fatalf("scheme = %d x %v, want no order", scheme.ReorderQuantity, scheme.UnitPrice)
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 被否决的零售商重新提交注册信息，重新进入待审核状态
+// 参数： 与 retailerRegistration 相同
+// 返回： 空
+func (t *MedicalSystem) retailerResubmit(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) < 11 || len(args) == 12 || len(args) > 15 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 11, 13, 14 or 15", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	// 解析参数
+	resubmitted, err := parseRetailerArgs(args)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	}
+	err = checkRetailerProfile(&resubmitted.RetailerProfile)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid retailer profile: %s", err), Payload: nil}
+	}
+	if resubmitted.Inventory < 0 {
+		return pb.Response{Status: 400, Message: "The inventory cannot be negative", Payload: nil}
+	}
+	retailerName := resubmitted.RetailerName
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+
+	// 验证调用者为该零售商的注册身份
+	err = checkRetailerOwner(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	// 只有被否决的零售商可以重新提交
+	if retailer.State != lib.Veto {
+		return pb.Response{Status: 400, Message: "Only a vetoed retailer can resubmit", Payload: nil}
+	}
+
+	// 绑定供应商，每个供应商都必须已注册且处于启用状态
+	err = checkActiveSuppliers(stub, resubmitted.Suppliers)
+	if err != nil {
+		return pb.Response{Status: 400, Message: err.Error(), Payload: nil}
+	}
+
+	// 使用新提交的信息，重新进入待审核状态
+	retailer.RetailerProfile = resubmitted.RetailerProfile
+	retailer.Inventory = resubmitted.Inventory
+	retailer.Suppliers = resubmitted.Suppliers
+	retailer.Supplier = ""
+	retailer.State = lib.ToBeResponded
+	retailer.PendingProfile = nil
+	retailer.ProfileState = ""
+
+	// 写入账本
+	err = putRetailer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Resubmit successful", Payload: nil}
+}
+
+// 零售商提出修改补货参数，修改在供应商审核通过后生效
+// 参数： 零售商名称 补货参数JSON（只需包含要修改的字段，如 {"lead_time":5,"review_cycle":7}）
+// 返回： 空
+func (t *MedicalSystem) retailerUpdateProfile(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+
+	// 验证调用者为该零售商的注册身份或成员
+	err = checkRetailerMember(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	// 验证零售商信息已由供应商审核通过
+	if retailer.State != lib.Pass {
+		return pb.Response{Status: 400, Message: "The retailer failed the audit", Payload: nil}
+	}
+	// 同一时间只能有一个待审核的修改，不能覆盖尚未审核的修改
+	if retailer.ProfileState == lib.ToBeResponded {
+		return pb.Response{Status: 400, Message: "The retailer already has a profile change waiting for audit", Payload: nil}
+	}
+
+	// 只保存要修改的字段，验证应用到当前补货参数上后仍是合法的补货参数
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal([]byte(args[1]), &fields)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid retailer profile: %s", err), Payload: nil}
+	}
+	if len(fields) == 0 {
+		return pb.Response{Status: 400, Message: "The profile change cannot be empty", Payload: nil}
+	}
+	patch, err := json.Marshal(fields)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+	_, err = applyProfilePatch(retailer.RetailerProfile, patch)
+	if err != nil {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid retailer profile: %s", err), Payload: nil}
+	}
+
+	// 保存为待审核的修改，审核前继续使用当前参数
+	retailer.PendingProfile = patch
+	retailer.ProfileState = lib.ToBeResponded
+
+	// 写入账本
+	err = putRetailer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Profile submitted for review", Payload: nil}
+}
+
+// 供货商通过与拒绝零售商的补货参数修改，通过时可重新约定补货策略
+// 参数： 供应商名称 零售商名称 回应（0或1） [补货策略名称 [策略参数JSON]]
+// 返回： 空
+func (t *MedicalSystem) supplierAuditProfile(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) < 3 || len(args) > 5 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 3 to 5", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空， 第三个参数必须为 0 或 1）
+	if args[0] == "" || args[1] == "" || args[2] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	if args[2] != "0" && args[2] != "1" {
+		return pb.Response{Status: 400, Message: "The response result must be 0 or 1", Payload: nil}
+	}
+	supplierName := args[0]
+	retailerName := args[1]
+	result := args[2]
+
+	// 读取账本，获取供应商
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	// 验证调用者为该供应商的管理员
+	err = checkSupplierAdmin(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if supplier.State != lib.Active {
+		return pb.Response{Status: 400, Message: "The supplier has been deactivated", Payload: nil}
+	}
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+
+	// 只能审核由该供应商负责补货的零售商
+	if retailer.Supplier != supplierName {
+		return pb.Response{Status: 403, Message: "Permission denied: the retailer is not served by this supplier", Payload: nil}
+	}
+	if retailer.PendingProfile == nil || retailer.ProfileState != lib.ToBeResponded {
+		return pb.Response{Status: 400, Message: "The retailer has no profile change to audit", Payload: nil}
+	}
+
+	// 根据回应应用或丢弃待审核的修改
+	if result == "1" {
+		// 只将修改的字段应用到当前补货参数上，提交后由库存上报更新的需求量均值等字段保持不变
+		profile, err := applyProfilePatch(retailer.RetailerProfile, retailer.PendingProfile)
+		if err != nil {
+			return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid retailer profile: %s", err), Payload: nil}
+		}
+		// 未指定补货策略时沿用当前策略，策略需适用于修改后的补货参数
+		retailer.Policy, err = parsePolicyArgs(stub, supplierName, retailerName, args[3:], retailer.Policy, profile)
+		if err != nil {
+			return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid replenishment policy: %s", err), Payload: nil}
+		}
+		// 修改了需求量均值时，需求量的估计从新的均值重新开始
+		if profile.AverageDemand != retailer.AverageDemand {
+			retailer.DemandEstimate = 0
+		}
+		retailer.RetailerProfile = *profile
+		retailer.ProfileState = lib.Pass
+	} else {
+		retailer.ProfileState = lib.Veto
+	}
+	retailer.PendingProfile = nil
+
+	// 写入账本
+	err = putRetailer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Audit successful", Payload: nil}
+}
+
+// 零售商注册身份添加成员，成员可以按证书的角色代表该零售商上报库存、回应补货方案等
+// 参数： 零售商名称 成员的客户端身份ID
+// 返回： 空
+func (t *MedicalSystem) retailerAddMember(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return updateRetailerMembers(stub, args, true)
+}
+
+// 零售商注册身份移除成员
+// 参数： 零售商名称 成员的客户端身份ID
+// 返回： 空
+func (t *MedicalSystem) retailerRemoveMember(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return updateRetailerMembers(stub, args, false)
+}
+
+// 添加或移除零售商的成员，只有注册身份可以修改成员列表
+func updateRetailerMembers(stub shim.ChaincodeStubInterface, args []string, add bool) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+	member := args[1]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+
+	// 验证调用者为该零售商的注册身份，成员不能修改成员列表
+	err = checkRetailerOwner(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	message := "Add member successful"
+	if add {
+		if member == retailer.Owner || utils.ContainsName(retailer.Members, member) {
+			return pb.Response{Status: 400, Message: "The identity is already a member of the retailer", Payload: nil}
+		}
+		retailer.Members = append(retailer.Members, member)
+	} else {
+		if !utils.ContainsName(retailer.Members, member) {
+			return pb.Response{Status: 400, Message: "The identity is not a member of the retailer", Payload: nil}
+		}
+		members := make([]string, 0, len(retailer.Members)-1)
+		for _, m := range retailer.Members {
+			if m != member {
+				members = append(members, m)
+			}
+		}
+		retailer.Members = members
+		message = "Remove member successful"
+	}
+
+	// 写入账本
+	err = putRetailer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put retailer error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: message, Payload: nil}
+}
+
+// 将只包含要修改字段的补货参数JSON应用到 profile 的副本上，未知字段视为错误，并验证修改后的补货参数
+func applyProfilePatch(profile lib.RetailerProfile, patch []byte) (*lib.RetailerProfile, error) {
+	decoder := json.NewDecoder(bytes.NewReader(patch))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&profile)
+	if err != nil {
+		return nil, err
+	}
+	err = checkRetailerProfile(&profile)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// 解析零售商注册参数，参数顺序与 retailerRegistration 一致，需求量标准差、目标服务水平、提前期标准差与最大存储容量可省略
+// 省略最大存储容量时为 0，即不限制补货后的库存量
+func parseRetailerArgs(args []string) (*lib.Retailer, error) {
+	retailer := &lib.Retailer{RetailerName: args[0]} // 零售商名称
+	var err error
+	// 将 string 转换为 float64 或 int，与 Retailer 结构体各属性的数据类型一致
+	if retailer.UnitPrice, err = strconv.ParseFloat(args[1], 64); err != nil { // 订货单价
+		return nil, err
+	}
+	if retailer.LeadTime, err = strconv.Atoi(args[2]); err != nil { // 提前期
+		return nil, err
+	}
+	if retailer.Inventory, err = strconv.Atoi(args[3]); err != nil { // 库存量
+		return nil, err
+	}
+	if retailer.AverageDemand, err = strconv.Atoi(args[4]); err != nil { // 需求量均值
+		return nil, err
+	}
+	if retailer.UpdateCycle, err = strconv.Atoi(args[5]); err != nil { // 上传数据的周期
+		return nil, err
+	}
+	if retailer.InventoryValue, err = strconv.ParseFloat(args[6], 64); err != nil { // 库存商品价值
+		return nil, err
+	}
+	if retailer.AnnualInterestRate, err = strconv.ParseFloat(args[7], 64); err != nil { // 年利率
+		return nil, err
+	}
+	if retailer.FixedOrderCost, err = strconv.ParseFloat(args[8], 64); err != nil { // 固定订货成本
+		return nil, err
+	}
+	if retailer.ReviewCycle, err = strconv.Atoi(args[9]); err != nil { // 审查周期
+		return nil, err
+	}
+	retailer.Suppliers = utils.SplitNames(args[10]) // 供应商名称列表
+	if len(args) > 11 {
+		if retailer.DemandStdDev, err = strconv.ParseFloat(args[11], 64); err != nil { // 需求量标准差
+			return nil, err
+		}
+		if retailer.ServiceLevel, err = strconv.ParseFloat(args[12], 64); err != nil { // 目标服务水平
+			return nil, err
+		}
+	}
+	if len(args) > 13 {
+		if retailer.LeadTimeStdDev, err = strconv.ParseFloat(args[13], 64); err != nil { // 提前期标准差
+			return nil, err
+		}
+	}
+	if len(args) > 14 {
+		if retailer.StorageCapacity, err = strconv.Atoi(args[14]); err != nil { // 最大存储容量
+			return nil, err
+		}
+	}
+	return retailer, nil
+}
+
+// 验证补货参数的取值范围
+func checkRetailerProfile(profile *lib.RetailerProfile) error {
+	if profile.UnitPrice <= 0 {
+		return fmt.Errorf("unit_price must be positive")
+	}
+	if profile.LeadTime < 0 || profile.AverageDemand < 0 {
+		return fmt.Errorf("lead_time and average_demand cannot be negative")
+	}
+	if profile.UpdateCycle <= 0 || profile.ReviewCycle <= 0 {
+		return fmt.Errorf("update_cycle and review_cycle must be positive")
+	}
+	if profile.InventoryValue < 0 || profile.AnnualInterestRate < 0 || profile.FixedOrderCost < 0 {
+		return fmt.Errorf("inventory_value, annual_interest_rate and fixed_order_cost cannot be negative")
+	}
+	if profile.StorageCapacity < 0 {
+		return fmt.Errorf("storage_capacity cannot be negative")
+	}
+	if profile.DemandStdDev < 0 || profile.LeadTimeStdDev < 0 {
+		return fmt.Errorf("demand_std_dev and lead_time_std_dev cannot be negative")
+	}
+	// 服务水平低于 50% 时 z 值为负，安全库存没有意义
+	if profile.ServiceLevel != 0 && (profile.ServiceLevel < 50 || profile.ServiceLevel >= 100) {
+		return fmt.Errorf("service_level must be 0 or in [50, 100)")
+	}
+	return nil
+}
+
+// 验证供应商名称列表非空，且每个供应商都已注册并处于启用状态
+func checkActiveSuppliers(stub shim.ChaincodeStubInterface, supplierNames []string) error {
+	if len(supplierNames) == 0 {
+		return fmt.Errorf("At least one supplier is required")
+	}
+	for _, supplierName := range supplierNames {
+		supplier, err := getSupplier(stub, supplierName)
+		if err != nil {
+			return fmt.Errorf("Get supplier %s error: %s", supplierName, err)
+		}
+		if supplier == nil {
+			return fmt.Errorf("The supplier %s does not exist", supplierName)
+		}
+		if supplier.State != lib.Active {
+			return fmt.Errorf("The supplier %s has been deactivated", supplierName)
+		}
+	}
+	return nil
+}
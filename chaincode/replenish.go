@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/policy"
+)
+
+// 根据零售商当前的库存、约定的补货策略与生效的补货配置生成新的补货方案，调用者需将零售商与新的补货方案写入账本
+// 尚未回应的旧补货方案被标记为已取代，并释放其占用的供应商库存
+func generateScheme(stub shim.ChaincodeStubInterface, retailer *lib.Retailer, supplierName string) (*lib.ReplenishmentScheme, error) {
+	config, err := getEffectiveConfig(stub, supplierName, retailer.RetailerName)
+	if err != nil {
+		return nil, err
+	}
+	policyName := retailer.Policy.Name
+	if policyName == "" {
+		policyName = config.DefaultPolicy
+	}
+	p, err := policy.Get(policyName)
+	if err != nil {
+		return nil, err
+	}
+	err = p.Check(&retailer.RetailerProfile)
+	if err != nil {
+		return nil, err
+	}
+	// 零售商约定的参数优先，其余参数使用配置中该策略的默认参数
+	params := make(map[string]float64)
+	for key, value := range config.DefaultPolicyParams[policyName] {
+		params[key] = value
+	}
+	for key, value := range retailer.Policy.Params {
+		params[key] = value
+	}
+
+	// 新的补货方案取代尚未回应的补货方案，释放其占用的供应商库存；已超过回应期限的补货方案标记为已过期
+	latest, err := getLatestScheme(stub, retailer)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		_, err = expireScheme(stub, latest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if latest != nil && isPendingScheme(latest) {
+		err = releaseAllocation(stub, latest)
+		if err != nil {
+			return nil, err
+		}
+		err = transitionScheme(latest, lib.Superseded)
+		if err != nil {
+			return nil, err
+		}
+		err = putScheme(stub, latest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scheme, err := newScheme(stub, retailer)
+	if err != nil {
+		return nil, err
+	}
+	// 按库存水平（现有库存加上已订货未收货的数量）计算，避免对在途的货物重复补货
+	position := retailer.Inventory + retailer.OnOrder
+	result := p.Calculate(&policy.Input{
+		Profile:       retailer.RetailerProfile,
+		Inventory:     position,
+		ResidualValue: config.ResidualValue,
+	}, params)
+	scheme.SupplierName = supplierName
+	scheme.ReorderQuantity = policy.Round(result.Quantity, config.Rounding, config.RoundingMultiple)
+	scheme.ResponseResults = lib.ToBeResponded
+	scheme.Policy = p.Name()
+	scheme.PolicyParams = result.Parameters
+	scheme.CostBreakdown = result.CostBreakdown
+
+	// 按该供应商设置的价格折扣表定价，没有折扣表时使用零售商的订货单价
+	breaks, err := getSupplierPriceBreaks(stub, retailer.RetailerName, supplierName)
+	if err != nil {
+		return nil, err
+	}
+	priceScheme(scheme, breaks, retailer.UnitPrice, config.RoundingMultiple)
+
+	// 补货后的库存量不能超过存储容量，削减后重新定价
+	if capScheme(scheme, retailer.StorageCapacity, position, config.RoundingMultiple) {
+		scheme.UnitPrice = priceFor(breaks, retailer.UnitPrice, scheme.ReorderQuantity)
+		scheme.TotalPrice = float64(scheme.ReorderQuantity) * scheme.UnitPrice
+	}
+	return scheme, nil
+}
+
+// 将补货方案的补货数量削减到存储容量减去库存量，返回是否削减
+func capScheme(scheme *lib.ReplenishmentScheme, capacity int, inventory int, multiple int) bool {
+	quantity := capQuantity(scheme.ReorderQuantity, capacity, inventory, multiple)
+	if quantity == scheme.ReorderQuantity {
+		return false
+	}
+	scheme.Capped = true
+	scheme.CappedBy = scheme.ReorderQuantity - quantity
+	scheme.ReorderQuantity = quantity
+	return true
+}
+
+// 补货后的库存量超过存储容量时，将补货数量削减到存储容量减去库存量，并向下取整为取整倍数；存储容量为 0 时不限制
+func capQuantity(quantity int, capacity int, inventory int, multiple int) int {
+	if capacity <= 0 || inventory+quantity <= capacity {
+		return quantity
+	}
+	space := capacity - inventory
+	if space < 0 {
+		space = 0
+	}
+	return policy.Round(float64(space), lib.RoundDown, multiple)
+}
+
+// 解析审核时约定的补货策略，参数为 [策略名称 [策略参数JSON]]，未指定时沿用 current
+func parsePolicyArgs(stub shim.ChaincodeStubInterface, supplierName string, retailerName string, args []string, current lib.PolicySetting, profile *lib.RetailerProfile) (lib.PolicySetting, error) {
+	setting := current
+	if len(args) > 0 {
+		setting = lib.PolicySetting{Name: args[0]}
+	}
+	if len(args) > 1 && strings.TrimSpace(args[1]) != "" {
+		err := json.Unmarshal([]byte(args[1]), &setting.Params)
+		if err != nil {
+			return setting, fmt.Errorf("invalid policy parameters: %s", err)
+		}
+	}
+	return setting, checkPolicySetting(stub, supplierName, retailerName, setting, profile)
+}
+
+// 验证补货策略能否用于该补货参数，未约定策略时验证配置中的默认策略
+// 约定的参数与配置中的默认参数合并后，订购点不能高于最大库存（s <= S，min <= max）
+func checkPolicySetting(stub shim.ChaincodeStubInterface, supplierName string, retailerName string, setting lib.PolicySetting, profile *lib.RetailerProfile) error {
+	config, err := getEffectiveConfig(stub, supplierName, retailerName)
+	if err != nil {
+		return err
+	}
+	name := setting.Name
+	if name == "" {
+		name = config.DefaultPolicy
+	}
+	err = policy.Validate(name, setting.Params, profile)
+	if err != nil {
+		return err
+	}
+	params := make(map[string]float64)
+	for key, value := range config.DefaultPolicyParams[name] {
+		params[key] = value
+	}
+	for key, value := range setting.Params {
+		params[key] = value
+	}
+	return policy.CheckBounds(name, params, &policy.Input{Profile: *profile, ResidualValue: config.ResidualValue})
+}
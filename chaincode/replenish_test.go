@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+func TestCapQuantity(t *testing.T) {
+	cases := []struct {
+		quantity, capacity, inventory, multiple int
+		want                                    int
+	}{
+		{50, 0, 100, 1, 50},   // 不限制存储容量
+		{50, 200, 100, 1, 50}, // 未超过存储容量
+		{50, 120, 100, 1, 20},
+		{50, 123, 100, 5, 20}, // 向下取整为取整倍数
+		{50, 90, 100, 1, 0},   // 库存已超过存储容量
+	}
+	for _, c := range cases {
+		if got := capQuantity(c.quantity, c.capacity, c.inventory, c.multiple); got != c.want {
+			t.Errorf("capQuantity(%d, %d, %d, %d) = %d, want %d", c.quantity, c.capacity, c.inventory, c.multiple, got, c.want)
+		}
+	}
+
+	scheme := &lib.ReplenishmentScheme{ReorderQuantity: 50}
+	if !capScheme(scheme, 120, 100, 1) || scheme.ReorderQuantity != 20 || !scheme.Capped || scheme.CappedBy != 30 {
+		t.Fatalf("capped scheme = %+v", scheme)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 逾期未上报库存的零售商
+type overdueRetailer struct {
+	RetailerName   string    // 零售商名称
+	LastReportTime time.Time // 最近一次上报库存的交易时间，为零值表示尚无上报记录
+	DueTime        time.Time // 应上报库存的截止时间（含允许的偏差）
+	DaysOverdue    float64   // 逾期天数
+	ReviewMissed   bool      // 距最近一次上报已超过审查周期，定期审查缺少最新的库存数据
+	Inventory      int       // 最近一次上报的库存量
+}
+
+// 供应商管理员查询逾期未上报库存的零售商，按逾期天数从多到少排列
+// 参数： 供应商名称
+// 返回： 逾期的零售商列表
+func (t *MedicalSystem) supplierOverdueReports(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
+	}
+
+	// 通过供应商索引查询由该供应商负责补货的零售商
+	retailerNames, err := listSupplierRetailers(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List retailers error: %s", err), Payload: nil}
+	}
+	overdueList := make([]overdueRetailer, 0)
+	for _, retailerName := range retailerNames {
+		retailer, err := getRetailer(stub, retailerName)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+		} else if retailer == nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("The retailer %s does not exist", retailerName), Payload: nil}
+		}
+		if retailer.Supplier != supplierName || retailer.State != lib.Pass {
+			continue
+		}
+		config, err := getEffectiveConfig(stub, supplierName, retailer.RetailerName)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get config error: %s", err), Payload: nil}
+		}
+		// 尚无上报记录的零售商（升级前审核通过）视为逾期，提醒其上报库存
+		item := overdueRetailer{
+			RetailerName:   retailer.RetailerName,
+			LastReportTime: retailer.LastReportTime,
+			Inventory:      retailer.Inventory,
+			ReviewMissed:   true,
+		}
+		if !retailer.LastReportTime.IsZero() {
+			item.DueTime = reportDueTime(retailer, config)
+			if !now.After(item.DueTime) {
+				continue
+			}
+			item.DaysOverdue = now.Sub(item.DueTime).Hours() / 24
+			item.ReviewMissed = now.Sub(retailer.LastReportTime) > days(float64(retailer.ReviewCycle))
+		}
+		overdueList = append(overdueList, item)
+	}
+	sort.SliceStable(overdueList, func(i, j int) bool {
+		if overdueList[i].LastReportTime.IsZero() != overdueList[j].LastReportTime.IsZero() {
+			return overdueList[i].LastReportTime.IsZero()
+		}
+		return overdueList[i].DaysOverdue > overdueList[j].DaysOverdue
+	})
+	// 序列化逾期的零售商列表
+	overdueListJSON, err := json.Marshal(overdueList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: overdueListJSON}
+}
+
+// 判断本次库存上报相对上报周期的状态：早于上次上报后的上报周期（减去允许的偏差）为提前，晚于截止时间为逾期
+// 尚无上报记录时视为按时
+func checkReportCycle(retailer *lib.Retailer, config *lib.ReplenishmentConfig, now time.Time) string {
+	if retailer.LastReportTime.IsZero() {
+		return lib.ReportOnTime
+	}
+	if now.Before(reportEarliestTime(retailer, config)) {
+		return lib.ReportEarly
+	}
+	if now.After(reportDueTime(retailer, config)) {
+		return lib.ReportLate
+	}
+	return lib.ReportOnTime
+}
+
+// 零售商下一次上报库存的最早时间：上次上报后经过上报周期，再减去允许的偏差
+func reportEarliestTime(retailer *lib.Retailer, config *lib.ReplenishmentConfig) time.Time {
+	return retailer.LastReportTime.Add(days(float64(retailer.UpdateCycle) - config.ReportTolerance))
+}
+
+// 零售商应上报库存的截止时间：上次上报后经过上报周期，再加上允许的偏差
+func reportDueTime(retailer *lib.Retailer, config *lib.ReplenishmentConfig) time.Time {
+	return retailer.LastReportTime.Add(days(float64(retailer.UpdateCycle) + config.ReportTolerance))
+}
+
+// 将天数转换为时间间隔
+func days(n float64) time.Duration {
+	return time.Duration(n * float64(24*time.Hour))
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 查看零售商的补货方案，不指定补货方案 ID 时返回最新的补货方案
+// 参数： 零售商名称 [补货方案ID]
+// 返回： 补货方案
+func (t *MedicalSystem) viewScheme(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 && len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1 or 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商或其供应商的管理员
+	err = checkSchemeViewer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	schemeID := ""
+	if len(args) == 2 {
+		schemeID = args[1]
+	}
+	scheme, err := findScheme(stub, retailer, schemeID)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get scheme error: %s", err), Payload: nil}
+	} else if scheme == nil {
+		return pb.Response{Status: 400, Message: "The scheme does not exist", Payload: nil}
+	}
+	// 超过回应期限的补货方案显示为已过期，不写入账本
+	err = showExpired(stub, scheme)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
+	}
+
+	// 序列化对象
+	schemeJSON, err := json.Marshal(scheme)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: schemeJSON}
+}
+
+// 查看零售商的全部补货方案
+// 参数： 零售商名称
+// 返回： 按序号排列的补货方案列表
+func (t *MedicalSystem) viewSchemeHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	retailerName := args[0]
+
+	// 读取账本，获取该零售商对象
+	retailer, err := getRetailer(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+	} else if retailer == nil {
+		return pb.Response{Status: 400, Message: "The retailer does not exist", Payload: nil}
+	}
+	// 验证调用者为该零售商或其供应商的管理员
+	err = checkSchemeViewer(stub, retailer)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+
+	schemesList, err := listSchemes(stub, retailerName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List schemes error: %s", err), Payload: nil}
+	}
+	// 超过回应期限的补货方案显示为已过期，不写入账本
+	err = showExpiredSchemes(stub, schemesList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
+	}
+	// 序列化补货方案列表
+	schemesListJSON, err := json.Marshal(schemesList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: schemesListJSON}
+}
+
+// 验证调用者为该零售商的注册身份或成员，或为其绑定的供应商的管理员
+func checkSchemeViewer(stub shim.ChaincodeStubInterface, retailer *lib.Retailer) error {
+	if checkRetailerMember(stub, retailer) == nil {
+		return nil
+	}
+	for _, supplierName := range retailer.Suppliers {
+		supplier, err := getSupplier(stub, supplierName)
+		if err != nil {
+			return err
+		}
+		if supplier != nil && checkSupplierAdmin(stub, supplier) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("the caller is neither retailer %s nor an admin of its suppliers", retailer.RetailerName)
+}
+
+// 获取零售商指定 ID 的补货方案，ID 为空时获取最新的补货方案，不存在时返回 nil
+func findScheme(stub shim.ChaincodeStubInterface, retailer *lib.Retailer, schemeID string) (*lib.ReplenishmentScheme, error) {
+	if schemeID == "" {
+		return getLatestScheme(stub, retailer)
+	}
+	// 在该零售商的补货方案中查找指定 ID
+	schemesList, err := listSchemes(stub, retailer.RetailerName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schemesList {
+		if schemesList[i].SchemeID == schemeID {
+			return &schemesList[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// 供应商管理员查询即将过期的补货方案：由该供应商负责、尚未回应且在指定天数内到达回应期限的补货方案，按回应期限排列
+// 参数： 供应商名称 [天数，默认为 1]
+// 返回： 补货方案列表
+func (t *MedicalSystem) supplierExpiringSchemes(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 && len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1 or 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	supplierName := args[0]
+	within := 1.0
+	if len(args) == 2 {
+		var err error
+		within, err = strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+		}
+		if within <= 0 {
+			return pb.Response{Status: 400, Message: "The number of days must be positive", Payload: nil}
+		}
+	}
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get transaction time error: %s", err), Payload: nil}
+	}
+
+	// 获取该供应商负责的各零售商最新的补货方案，已超过回应期限的显示为已过期，不写入账本
+	schemesList, err := listLatestSchemes(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List schemes error: %s", err), Payload: nil}
+	}
+	err = showExpiredSchemes(stub, schemesList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Check scheme expiry error: %s", err), Payload: nil}
+	}
+	// 筛选尚未回应且即将到达回应期限的补货方案
+	limit := now.Add(days(within))
+	expiringList := make([]lib.ReplenishmentScheme, 0)
+	for i := range schemesList {
+		scheme := &schemesList[i]
+		if !isPendingScheme(scheme) || scheme.ResponseDeadline.IsZero() || scheme.ResponseDeadline.After(limit) {
+			continue
+		}
+		expiringList = append(expiringList, *scheme)
+	}
+	sort.SliceStable(expiringList, func(i, j int) bool {
+		return expiringList[i].ResponseDeadline.Before(expiringList[j].ResponseDeadline)
+	})
+	// 序列化补货方案列表
+	expiringListJSON, err := json.Marshal(expiringList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: expiringListJSON}
+}
+
+// 供应商管理员将由该供应商负责、超过回应期限仍未回应的补货方案标记为已过期，并释放其占用的库存
+// 回应已过期的补货方案会被拒绝且不写入账本，查看补货方案也只显示过期状态，由该函数记录过期状态并释放库存
+// 参数： 供应商名称
+// 返回： 本次标记为已过期的补货方案列表
+func (t *MedicalSystem) supplierExpireSchemes(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+
+	// 获取该供应商负责的各零售商最新的补货方案中尚待回应的补货方案
+	schemesList, err := listLatestSchemes(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List schemes error: %s", err), Payload: nil}
+	}
+	pending := make([]lib.ReplenishmentScheme, 0)
+	for i := range schemesList {
+		if isPendingScheme(&schemesList[i]) {
+			pending = append(pending, schemesList[i])
+		}
+	}
+	resp = putExpiredSchemes(stub, supplierName, pending)
+	if resp != nil {
+		return *resp
+	}
+	expiredList := make([]lib.ReplenishmentScheme, 0)
+	for _, scheme := range pending {
+		if scheme.ResponseResults == lib.Expired {
+			expiredList = append(expiredList, scheme)
+		}
+	}
+	// 序列化补货方案列表
+	expiredListJSON, err := json.Marshal(expiredList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Expire successful", Payload: expiredListJSON}
+}
+
+// 验证补货方案能否从当前的回应结果转换为 to
+func checkSchemeTransition(scheme *lib.ReplenishmentScheme, to string) error {
+	for _, state := range lib.SchemeTransitions[scheme.ResponseResults] {
+		if state == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("the scheme cannot change from %s to %s", scheme.ResponseResults, to)
+}
+
+// 按允许的状态转换修改补货方案的回应结果，不允许时返回错误且不修改
+func transitionScheme(scheme *lib.ReplenishmentScheme, to string) error {
+	err := checkSchemeTransition(scheme, to)
+	if err != nil {
+		return err
+	}
+	scheme.ResponseResults = to
+	return nil
+}
+
+// 补货方案是否已超过回应期限仍未回应
+func schemeExpired(stub shim.ChaincodeStubInterface, scheme *lib.ReplenishmentScheme) (bool, error) {
+	if !isPendingScheme(scheme) || scheme.ResponseDeadline.IsZero() {
+		return false, nil
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return false, err
+	}
+	return now.After(scheme.ResponseDeadline), nil
+}
+
+// 超过回应期限仍未回应的补货方案标记为已过期，释放其占用的供应商库存并写入账本，返回是否过期
+// 生成新补货方案时调用，过期的方案不再记为被取代
+func expireScheme(stub shim.ChaincodeStubInterface, scheme *lib.ReplenishmentScheme) (bool, error) {
+	expired, err := schemeExpired(stub, scheme)
+	if err != nil || !expired {
+		return false, err
+	}
+	err = releaseAllocation(stub, scheme)
+	if err != nil {
+		return false, err
+	}
+	err = transitionScheme(scheme, lib.Expired)
+	if err != nil {
+		return false, err
+	}
+	return true, putScheme(stub, scheme)
+}
+
+// 将同一供应商的补货方案中超过回应期限的标记为已过期并写入账本，返回过期的数量
+// 占用的库存只从内存中的 supplier 释放，同一交易读不到本交易的写入，调用者在最后将供应商写入账本一次
+func expireSchemes(stub shim.ChaincodeStubInterface, supplier *lib.Supplier, schemes []lib.ReplenishmentScheme) (int, error) {
+	count := 0
+	for i := range schemes {
+		scheme := &schemes[i]
+		expired, err := schemeExpired(stub, scheme)
+		if err != nil {
+			return count, err
+		}
+		if !expired {
+			continue
+		}
+		releaseReservation(supplier, scheme)
+		err = transitionScheme(scheme, lib.Expired)
+		if err != nil {
+			return count, err
+		}
+		err = putScheme(stub, scheme)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// 超过回应期限仍未回应的补货方案在查看结果中显示为已过期
+// 只修改内存中的副本，不写入账本也不释放库存，查看函数不产生写集，审计员等只读角色也不会修改账本
+func showExpired(stub shim.ChaincodeStubInterface, scheme *lib.ReplenishmentScheme) error {
+	expired, err := schemeExpired(stub, scheme)
+	if err != nil || !expired {
+		return err
+	}
+	return transitionScheme(scheme, lib.Expired)
+}
+
+// 将补货方案列表中超过回应期限仍未回应的显示为已过期，不写入账本
+func showExpiredSchemes(stub shim.ChaincodeStubInterface, schemes []lib.ReplenishmentScheme) error {
+	for i := range schemes {
+		err := showExpired(stub, &schemes[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 读取账本，获取供应商，将其补货方案中超过回应期限的标记为已过期，有补货方案过期时将释放库存后的供应商写入账本一次
+func putExpiredSchemes(stub shim.ChaincodeStubInterface, supplierName string, schemes []lib.ReplenishmentScheme) *pb.Response {
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return &pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	count, err := expireSchemes(stub, supplier, schemes)
+	if err != nil {
+		return &pb.Response{Status: 500, Message: fmt.Sprintf("Expire scheme error: %s", err), Payload: nil}
+	}
+	if count > 0 {
+		err = putSupplier(stub, supplier)
+		if err != nil {
+			return &pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier error: %s", err), Payload: nil}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 读取账本中 key 对应的 JSON 并反序列化到 value，key 不存在时返回 false
+func getStateJSON(stub shim.ChaincodeStubInterface, key string, value interface{}) (bool, error) {
+	valueJSON, err := stub.GetState(key)
+	if err != nil {
+		return false, err
+	}
+	if valueJSON == nil {
+		return false, nil
+	}
+	err = json.Unmarshal(valueJSON, value)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 序列化 value 并写入账本
+func putStateJSON(stub shim.ChaincodeStubInterface, key string, value interface{}) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, valueJSON)
+}
+
+// 读取账本，获取零售商，不存在时返回 nil
+func getRetailer(stub shim.ChaincodeStubInterface, retailerName string) (*lib.Retailer, error) {
+	key, err := utils.ConstructRetailerKey(stub, retailerName)
+	if err != nil {
+		return nil, err
+	}
+	retailer := new(lib.Retailer)
+	found, err := getStateJSON(stub, key, retailer)
+	if err != nil || !found {
+		return nil, err
+	}
+	return retailer, nil
+}
+
+// 将零售商写入账本，并记录修改者身份
+func putRetailer(stub shim.ChaincodeStubInterface, retailer *lib.Retailer) error {
+	key, err := utils.ConstructRetailerKey(stub, retailer.RetailerName)
+	if err != nil {
+		return err
+	}
+	retailer.ModifiedMSP, retailer.ModifiedBy, err = utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, retailer)
+}
+
+// 读取账本，获取零售商指定序号的补货方案，不存在时返回 nil
+func getScheme(stub shim.ChaincodeStubInterface, retailerName string, sequence int) (*lib.ReplenishmentScheme, error) {
+	key, err := utils.ConstructSchemeKey(stub, retailerName, sequence)
+	if err != nil {
+		return nil, err
+	}
+	scheme := new(lib.ReplenishmentScheme)
+	found, err := getStateJSON(stub, key, scheme)
+	if err != nil || !found {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// 读取账本，获取零售商最新的补货方案，不存在时返回 nil
+func getLatestScheme(stub shim.ChaincodeStubInterface, retailer *lib.Retailer) (*lib.ReplenishmentScheme, error) {
+	if retailer.SchemeSeq == 0 {
+		return nil, nil
+	}
+	return getScheme(stub, retailer.RetailerName, retailer.SchemeSeq)
+}
+
+// 读取账本，按序号顺序获取零售商的全部补货方案
+func listSchemes(stub shim.ChaincodeStubInterface, retailerName string) ([]lib.ReplenishmentScheme, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(lib.ObjectTypeScheme, []string{retailerName})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	schemesList := make([]lib.ReplenishmentScheme, 0)
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var scheme lib.ReplenishmentScheme
+		err = json.Unmarshal(kv.Value, &scheme)
+		if err != nil {
+			return nil, err
+		}
+		schemesList = append(schemesList, scheme)
+	}
+	return schemesList, nil
+}
+
+// 为零售商分配下一个补货方案序号并创建补货方案，调用者需将零售商与补货方案写入账本
+func newScheme(stub shim.ChaincodeStubInterface, retailer *lib.Retailer) (*lib.ReplenishmentScheme, error) {
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	retailer.SchemeSeq++
+	scheme := &lib.ReplenishmentScheme{
+		SchemeID:     stub.GetTxID(),
+		Sequence:     retailer.SchemeSeq,
+		CreateTime:   txTime,
+		RetailerName: retailer.RetailerName,
+	}
+	// 零售商需在一个审查周期内回应补货方案
+	if retailer.ReviewCycle > 0 {
+		scheme.ResponseDeadline = txTime.Add(days(float64(retailer.ReviewCycle)))
+	}
+	return scheme, nil
+}
+
+// 将补货方案写入账本，并记录修改者身份，已有序号的补货方案会被更新
+func putScheme(stub shim.ChaincodeStubInterface, scheme *lib.ReplenishmentScheme) error {
+	key, err := utils.ConstructSchemeKey(stub, scheme.RetailerName, scheme.Sequence)
+	if err != nil {
+		return err
+	}
+	scheme.ModifiedMSP, scheme.ModifiedBy, err = utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, scheme)
+}
+
+// 通过供应商索引查询由该供应商负责补货的零售商，按零售商序号直接读取各自最新的补货方案
+func listLatestSchemes(stub shim.ChaincodeStubInterface, supplierName string) ([]lib.ReplenishmentScheme, error) {
+	retailerNames, err := listSupplierRetailers(stub, supplierName)
+	if err != nil {
+		return nil, err
+	}
+	supplierSchemes := make([]lib.ReplenishmentScheme, 0, len(retailerNames))
+	for _, retailerName := range retailerNames {
+		retailer, err := getRetailer(stub, retailerName)
+		if err != nil {
+			return nil, err
+		} else if retailer == nil {
+			return nil, fmt.Errorf("the retailer %s does not exist", retailerName)
+		}
+		scheme, err := getLatestScheme(stub, retailer)
+		if err != nil {
+			return nil, err
+		}
+		if scheme != nil && scheme.SupplierName == supplierName {
+			supplierSchemes = append(supplierSchemes, *scheme)
+		}
+	}
+	return supplierSchemes, nil
+}
+
+// 将零售商加入供应商负责补货的零售商索引
+func putSupplierRetailer(stub shim.ChaincodeStubInterface, supplierName string, retailerName string) error {
+	key, err := utils.ConstructSupplierRetailerKey(stub, supplierName, retailerName)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, []byte(retailerName))
+}
+
+// 通过组合键前缀查询由该供应商负责补货的零售商名称，按名称排列
+func listSupplierRetailers(stub shim.ChaincodeStubInterface, supplierName string) ([]string, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(lib.ObjectTypeSupplierRetailer, []string{supplierName})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	retailerNames := make([]string, 0)
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		retailerNames = append(retailerNames, string(kv.Value))
+	}
+	return retailerNames, nil
+}
+
+// 读取账本，获取零售商指定序号的采购订单，不存在时返回 nil
+func getOrder(stub shim.ChaincodeStubInterface, retailerName string, sequence int) (*lib.PurchaseOrder, error) {
+	key, err := utils.ConstructOrderKey(stub, retailerName, sequence)
+	if err != nil {
+		return nil, err
+	}
+	order := new(lib.PurchaseOrder)
+	found, err := getStateJSON(stub, key, order)
+	if err != nil || !found {
+		return nil, err
+	}
+	return order, nil
+}
+
+// 通过组合键前缀查询零售商的全部采购订单，按序号排列
+func listOrders(stub shim.ChaincodeStubInterface, retailerName string) ([]lib.PurchaseOrder, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(lib.ObjectTypeOrder, []string{retailerName})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	ordersList := make([]lib.PurchaseOrder, 0)
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var order lib.PurchaseOrder
+		err = json.Unmarshal(kv.Value, &order)
+		if err != nil {
+			return nil, err
+		}
+		ordersList = append(ordersList, order)
+	}
+	return ordersList, nil
+}
+
+// 将采购订单写入账本，并记录修改者身份
+func putOrder(stub shim.ChaincodeStubInterface, order *lib.PurchaseOrder) error {
+	key, err := utils.ConstructOrderKey(stub, order.RetailerName, order.Sequence)
+	if err != nil {
+		return err
+	}
+	order.ModifiedMSP, order.ModifiedBy, err = utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, order)
+}
+
+// 读取账本，获取零售商指定序号的索赔，不存在时返回 nil
+func getClaim(stub shim.ChaincodeStubInterface, retailerName string, sequence int) (*lib.Claim, error) {
+	key, err := utils.ConstructClaimKey(stub, retailerName, sequence)
+	if err != nil {
+		return nil, err
+	}
+	claim := new(lib.Claim)
+	found, err := getStateJSON(stub, key, claim)
+	if err != nil || !found {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// 通过组合键前缀查询零售商的全部索赔，按序号排列
+func listClaims(stub shim.ChaincodeStubInterface, retailerName string) ([]lib.Claim, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(lib.ObjectTypeClaim, []string{retailerName})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	claimsList := make([]lib.Claim, 0)
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var claim lib.Claim
+		err = json.Unmarshal(kv.Value, &claim)
+		if err != nil {
+			return nil, err
+		}
+		claimsList = append(claimsList, claim)
+	}
+	return claimsList, nil
+}
+
+// 将索赔写入账本，并记录修改者身份
+func putClaim(stub shim.ChaincodeStubInterface, claim *lib.Claim) error {
+	key, err := utils.ConstructClaimKey(stub, claim.RetailerName, claim.Sequence)
+	if err != nil {
+		return err
+	}
+	claim.ModifiedMSP, claim.ModifiedBy, err = utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, claim)
+}
+
+// 读取账本，获取供应商，不存在时返回 nil
+func getSupplier(stub shim.ChaincodeStubInterface, supplierName string) (*lib.Supplier, error) {
+	key, err := utils.ConstructSupplierKey(stub, supplierName)
+	if err != nil {
+		return nil, err
+	}
+	supplier := new(lib.Supplier)
+	found, err := getStateJSON(stub, key, supplier)
+	if err != nil || !found {
+		return nil, err
+	}
+	return supplier, nil
+}
+
+// 将供应商写入账本
+func putSupplier(stub shim.ChaincodeStubInterface, supplier *lib.Supplier) error {
+	key, err := utils.ConstructSupplierKey(stub, supplier.SupplierName)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, supplier)
+}
+
+// 读取账本中的配置项到 value，配置项不存在时返回 false
+func getConfig(stub shim.ChaincodeStubInterface, name string, value interface{}) (bool, error) {
+	key, err := utils.ConstructConfigKey(stub, name)
+	if err != nil {
+		return false, err
+	}
+	return getStateJSON(stub, key, value)
+}
+
+// 将配置项写入账本
+func putConfig(stub shim.ChaincodeStubInterface, name string, value interface{}) error {
+	key, err := utils.ConstructConfigKey(stub, name)
+	if err != nil {
+		return err
+	}
+	return putStateJSON(stub, key, value)
+}
+
+// 读取账本中 key 的全部历史版本，修改者身份取自各版本记录中的 modified_by 与 modified_msp
+func getKeyHistory(stub shim.ChaincodeStubInterface, key string) ([]lib.HistoryRecord, error) {
+	iterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	records := make([]lib.HistoryRecord, 0)
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		record := lib.HistoryRecord{
+			TxID:     modification.GetTxId(),
+			IsDelete: modification.GetIsDelete(),
+		}
+		if ts := modification.GetTimestamp(); ts != nil {
+			record.Timestamp = time.Unix(ts.Seconds, int64(ts.Nanos)).UTC()
+		}
+		if !record.IsDelete {
+			record.Value = json.RawMessage(modification.GetValue())
+			// 早期版本的记录没有修改者字段，解析失败或缺失时保持为空
+			var modifier struct {
+				ModifiedBy  string `json:"modified_by"`
+				ModifiedMSP string `json:"modified_msp"`
+			}
+			if json.Unmarshal(record.Value, &modifier) == nil {
+				record.ModifiedBy = modifier.ModifiedBy
+				record.ModifiedMSP = modifier.ModifiedMSP
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// 补全事件的名称、交易 ID 与交易时间后发出链码事件，同一交易中后发出的事件会覆盖之前的事件
+func emitEvent(stub shim.ChaincodeStubInterface, name string, event *lib.Event) error {
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	event.Type = name
+	event.TxID = stub.GetTxID()
+	event.Timestamp = txTime
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(name, eventJSON)
+}
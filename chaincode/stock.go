@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+)
+
+// 库存配给的结果
+type allocationResult struct {
+	Mode        string       // 配给方式
+	Stock       int          // 供应商库存量
+	Available   int          // 配给前的可用库存量（已释放原有分配）
+	Requested   int          // 待回应与协商中补货方案的补货数量之和
+	Allocations []allocation // 各补货方案的配给
+}
+
+// 单个补货方案的配给
+type allocation struct {
+	RetailerName      string           // 零售商名称
+	SchemeID          string           // 补货方案 ID
+	ReorderQuantity   int              // 补货数量，协商中的补货方案为最后一轮还价的数量
+	AllocatedQuantity int              // 配给数量
+	inventory         int              // 零售商的库存水平（库存量加未收货数量），按满足率配给时使用
+	priority          int              // 优先级，按优先级配给时使用
+	basePrice         float64          // 零售商的订货单价，按配给数量重新定价时使用
+	breaks            []lib.PriceBreak // 零售商的价格折扣档位，按配给数量重新定价时使用
+}
+
+// 供应商管理员更新供应商库存量，库存量不能少于已分配给待回应补货方案的数量
+// 参数： 供应商名称 库存量
+// 返回： 供应商
+func (t *MedicalSystem) supplierUpdateStock(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" || args[1] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+	stock, err := strconv.Atoi(args[1])
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Conversion of data type failed: %s", err), Payload: nil}
+	}
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	}
+	if stock < supplier.Reserved {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The stock cannot be less than the reserved quantity %d", supplier.Reserved), Payload: nil}
+	}
+
+	supplier.Stock = stock
+	err = putSupplier(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier error: %s", err), Payload: nil}
+	}
+	// 序列化对象
+	supplierJSON, err := json.Marshal(supplier)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Update stock successful", Payload: supplierJSON}
+}
+
+// 供应商管理员将库存配给该供应商所有待回应与协商中的补货方案，原有的分配会被释放后重新配给
+// 库存足够时按补货数量分配，不足时按配给方式分配，零售商同意补货方案时按配给数量补货
+// 参数： 供应商名称 配给方式（proportional、priority、fillrate） [零售商优先级JSON（如 {"lingshou1":2}），按优先级配给时必填]
+// 返回： 配给结果
+func (t *MedicalSystem) supplierAllocateStock(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 2 && len(args) != 3 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 2 or 3", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	supplierName := args[0]
+	mode := args[1]
+	if mode != lib.AllocateProportional && mode != lib.AllocatePriority && mode != lib.AllocateFillRate {
+		return pb.Response{Status: 400, Message: fmt.Sprintf("Unknown allocation mode: %s", mode), Payload: nil}
+	}
+	priorities := make(map[string]int)
+	if mode == lib.AllocatePriority {
+		if len(args) != 3 {
+			return pb.Response{Status: 400, Message: "The priority allocation requires retailer priorities", Payload: nil}
+		}
+		err := json.Unmarshal([]byte(args[2]), &priorities)
+		if err != nil {
+			return pb.Response{Status: 400, Message: fmt.Sprintf("Invalid priorities: %s", err), Payload: nil}
+		}
+	}
+
+	// 验证调用者为该供应商的管理员
+	resp := checkActiveSupplierAdmin(stub, supplierName)
+	if resp != nil {
+		return *resp
+	}
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	}
+
+	// 获取该供应商待回应与协商中的补货方案，并释放原有的分配
+	schemesList, err := listLatestSchemes(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("List schemes error: %s", err), Payload: nil}
+	}
+	// 超过回应期限的补货方案标记为已过期，不参与配给（释放的库存与配给结果一起在最后写入账本）
+	_, err = expireSchemes(stub, supplier, schemesList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Expire scheme error: %s", err), Payload: nil}
+	}
+	pending := make([]lib.ReplenishmentScheme, 0)
+	allocations := make([]allocation, 0)
+	requested := 0
+	for i := range schemesList {
+		scheme := &schemesList[i]
+		if !isPendingScheme(scheme) {
+			continue
+		}
+		releaseReservation(supplier, scheme)
+		retailer, err := getRetailer(stub, scheme.RetailerName)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get retailer error: %s", err), Payload: nil}
+		} else if retailer == nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("The retailer %s does not exist", scheme.RetailerName), Payload: nil}
+		}
+		breaks, err := getSupplierPriceBreaks(stub, scheme.RetailerName, supplierName)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Get price breaks error: %s", err), Payload: nil}
+		}
+		// 协商中的补货方案按最后一轮还价的数量配给
+		quantity := scheme.ReorderQuantity
+		if n := len(scheme.Negotiation); n > 0 {
+			quantity = scheme.Negotiation[n-1].Quantity
+		}
+		pending = append(pending, *scheme)
+		allocations = append(allocations, allocation{
+			RetailerName:    scheme.RetailerName,
+			SchemeID:        scheme.SchemeID,
+			ReorderQuantity: quantity,
+			inventory:       retailer.Inventory + retailer.OnOrder,
+			priority:        priorities[scheme.RetailerName],
+			basePrice:       retailer.UnitPrice,
+			breaks:          breaks,
+		})
+		requested += quantity
+	}
+	available := supplier.Stock - supplier.Reserved
+	if available < 0 {
+		available = 0
+	}
+
+	// 库存足够时按补货数量分配，否则按配给方式分配
+	if requested <= available {
+		for i := range allocations {
+			allocations[i].AllocatedQuantity = allocations[i].ReorderQuantity
+		}
+	} else {
+		switch mode {
+		case lib.AllocateProportional:
+			allocateProportional(allocations, available)
+		case lib.AllocatePriority:
+			allocatePriority(allocations, available)
+		case lib.AllocateFillRate:
+			allocateFillRate(allocations, available)
+		}
+	}
+
+	// 记录配给结果，按配给数量所在的价格折扣档位重新定价
+	for i := range pending {
+		scheme := &pending[i]
+		a := &allocations[i]
+		scheme.Allocated = true
+		scheme.AllocatedQuantity = a.AllocatedQuantity
+		scheme.AllocationMode = mode
+		scheme.UnitPrice = priceFor(a.breaks, a.basePrice, scheme.AllocatedQuantity)
+		scheme.TotalPrice = float64(scheme.AllocatedQuantity) * scheme.UnitPrice
+		supplier.Reserved += scheme.AllocatedQuantity
+		err = putScheme(stub, scheme)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Put scheme error: %s", err), Payload: nil}
+		}
+	}
+	err = putSupplier(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier error: %s", err), Payload: nil}
+	}
+
+	// 序列化配给结果
+	resJSON, err := json.Marshal(allocationResult{
+		Mode:        mode,
+		Stock:       supplier.Stock,
+		Available:   available,
+		Requested:   requested,
+		Allocations: allocations,
+	})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Allocate stock successful", Payload: resJSON}
+}
+
+// 按补货数量的比例配给，向下取整后剩余的库存按小数部分从大到小逐个分配
+func allocateProportional(allocations []allocation, available int) {
+	requested := 0
+	for _, a := range allocations {
+		requested += a.ReorderQuantity
+	}
+	if requested == 0 {
+		return
+	}
+	fractions := make([]float64, len(allocations))
+	remaining := available
+	for i := range allocations {
+		share := float64(allocations[i].ReorderQuantity) * float64(available) / float64(requested)
+		allocations[i].AllocatedQuantity = int(math.Floor(share))
+		fractions[i] = share - math.Floor(share)
+		remaining -= allocations[i].AllocatedQuantity
+	}
+	order := make([]int, len(allocations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return fractions[order[i]] > fractions[order[j]] })
+	for _, i := range order {
+		if remaining == 0 {
+			break
+		}
+		if allocations[i].AllocatedQuantity < allocations[i].ReorderQuantity {
+			allocations[i].AllocatedQuantity++
+			remaining--
+		}
+	}
+}
+
+// 按优先级从高到低满足补货数量，优先级相同时按补货方案的顺序
+func allocatePriority(allocations []allocation, available int) {
+	order := make([]int, len(allocations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return allocations[order[i]].priority > allocations[order[j]].priority })
+	remaining := available
+	for _, i := range order {
+		quantity := allocations[i].ReorderQuantity
+		if quantity > remaining {
+			quantity = remaining
+		}
+		allocations[i].AllocatedQuantity = quantity
+		remaining -= quantity
+	}
+}
+
+// 按满足率配给：满足率 =（库存量 + 配给数量）/（库存量 + 补货数量），
+// 先二分查找所有零售商能共同达到的最高满足率，剩余的库存逐个分配给当前满足率最低的零售商
+func allocateFillRate(allocations []allocation, available int) {
+	target := func(a allocation) float64 { return float64(a.inventory + a.ReorderQuantity) }
+	level := func(rate float64) int {
+		total := 0
+		for i := range allocations {
+			quantity := int(math.Floor(rate*target(allocations[i]))) - allocations[i].inventory
+			if quantity < 0 {
+				quantity = 0
+			} else if quantity > allocations[i].ReorderQuantity {
+				quantity = allocations[i].ReorderQuantity
+			}
+			allocations[i].AllocatedQuantity = quantity
+			total += quantity
+		}
+		return total
+	}
+	low, high := 0.0, 1.0
+	for k := 0; k < 64; k++ {
+		mid := (low + high) / 2
+		if level(mid) <= available {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	remaining := available - level(low)
+	for remaining > 0 {
+		best := -1
+		bestRate := 0.0
+		for i, a := range allocations {
+			if a.AllocatedQuantity >= a.ReorderQuantity || target(a) <= 0 {
+				continue
+			}
+			rate := float64(a.inventory+a.AllocatedQuantity) / target(a)
+			if best < 0 || rate < bestRate {
+				best, bestRate = i, rate
+			}
+		}
+		if best < 0 {
+			break
+		}
+		allocations[best].AllocatedQuantity++
+		remaining--
+	}
+}
+
+// 释放待回应或协商中的补货方案占用的供应商库存并写入账本，调用者需自行更新补货方案
+func releaseAllocation(stub shim.ChaincodeStubInterface, scheme *lib.ReplenishmentScheme) error {
+	if !scheme.Allocated || !isPendingScheme(scheme) {
+		return nil
+	}
+	supplier, err := getSupplier(stub, scheme.SupplierName)
+	if err != nil || supplier == nil {
+		return err
+	}
+	releaseReservation(supplier, scheme)
+	return putSupplier(stub, supplier)
+}
+
+// 从内存中的供应商释放待回应或协商中的补货方案占用的库存，不写入账本
+func releaseReservation(supplier *lib.Supplier, scheme *lib.ReplenishmentScheme) {
+	if !scheme.Allocated || !isPendingScheme(scheme) {
+		return
+	}
+	supplier.Reserved -= scheme.AllocatedQuantity
+	if supplier.Reserved < 0 {
+		supplier.Reserved = 0
+	}
+}
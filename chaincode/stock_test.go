@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+)
+
+func allocated(allocations []allocation) []int {
+	quantities := make([]int, len(allocations))
+	for i, a := range allocations {
+		quantities[i] = a.AllocatedQuantity
+	}
+	return quantities
+}
+
+func sum(quantities []int) int {
+	total := 0
+	for _, q := range quantities {
+		total += q
+	}
+	return total
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAllocateProportional(t *testing.T) {
+	allocations := []allocation{
+		{RetailerName: "a", ReorderQuantity: 10},
+		{RetailerName: "b", ReorderQuantity: 20},
+		{RetailerName: "c", ReorderQuantity: 30},
+	}
+	allocateProportional(allocations, 30)
+	if got := allocated(allocations); !equalInts(got, []int{5, 10, 15}) {
+		t.Fatalf("allocated %v, want [5 10 15]", got)
+	}
+
+	// 向下取整后剩余的库存按小数部分从大到小分配
+	allocations = []allocation{
+		{RetailerName: "a", ReorderQuantity: 1},
+		{RetailerName: "b", ReorderQuantity: 1},
+		{RetailerName: "c", ReorderQuantity: 1},
+	}
+	allocateProportional(allocations, 2)
+	if got := allocated(allocations); sum(got) != 2 || !equalInts(got, []int{1, 1, 0}) {
+		t.Fatalf("allocated %v, want [1 1 0]", got)
+	}
+}
+
+func TestAllocatePriority(t *testing.T) {
+	allocations := []allocation{
+		{RetailerName: "a", ReorderQuantity: 10, priority: 1},
+		{RetailerName: "b", ReorderQuantity: 20, priority: 3},
+		{RetailerName: "c", ReorderQuantity: 30, priority: 2},
+	}
+	allocatePriority(allocations, 40)
+	if got := allocated(allocations); !equalInts(got, []int{0, 20, 20}) {
+		t.Fatalf("allocated %v, want [0 20 20]", got)
+	}
+
+	// 优先级相同时按补货方案的顺序
+	allocations = []allocation{
+		{RetailerName: "a", ReorderQuantity: 10},
+		{RetailerName: "b", ReorderQuantity: 10},
+	}
+	allocatePriority(allocations, 15)
+	if got := allocated(allocations); !equalInts(got, []int{10, 5}) {
+		t.Fatalf("allocated %v, want [10 5]", got)
+	}
+}
+
+func TestAllocateFillRate(t *testing.T) {
+	// 满足率 =（库存量 + 配给数量）/（库存量 + 补货数量），库存低的零售商先得到配给
+	allocations := []allocation{
+		{RetailerName: "a", ReorderQuantity: 50, inventory: 50},
+		{RetailerName: "b", ReorderQuantity: 100, inventory: 0},
+	}
+	allocateFillRate(allocations, 60)
+	got := allocated(allocations)
+	if sum(got) != 60 {
+		t.Fatalf("allocated %v, want a total of 60", got)
+	}
+	// 共同满足率为 (50 + 0 + 60) / 200 = 0.55：a 补到 55，b 补到 55
+	if !equalInts(got, []int{5, 55}) {
+		t.Fatalf("allocated %v, want [5 55]", got)
+	}
+	for _, a := range allocations {
+		if a.AllocatedQuantity > a.ReorderQuantity || a.AllocatedQuantity < 0 {
+			t.Fatalf("allocation %+v out of range", a)
+		}
+	}
+}
+
+func TestAllocateFillRateSurplus(t *testing.T) {
+	// 库存充足的零售商只补到共同满足率，配给数量不超过补货数量
+	allocations := []allocation{
+		{RetailerName: "a", ReorderQuantity: 10, inventory: 90},
+		{RetailerName: "b", ReorderQuantity: 10, inventory: 0},
+	}
+	allocateFillRate(allocations, 12)
+	got := allocated(allocations)
+	if sum(got) != 12 || got[0] > 3 || got[1] < 9 || got[1] > 10 {
+		t.Fatalf("allocated %v, want most of 12 to b", got)
+	}
+}
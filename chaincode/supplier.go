@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/vendor-manage-inventory/chaincode/lib"
+	"github.com/vendor-manage-inventory/chaincode/utils"
+)
+
+// 通道管理员注册供应商，并指定供应商的初始管理员
+// 参数： 供应商名称 [初始管理员身份ID，默认为调用者]
+// 返回： 空
+func (t *MedicalSystem) supplierRegistration(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 && len(args) != 2 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1 or 2", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	for _, arg := range args {
+		if arg == "" {
+			return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+		}
+	}
+	supplierName := args[0]
+
+	// 只有通道管理员可以注册供应商，避免任意供应商组织成员自行注册并成为管理员
+	err := checkChannelAdmin(stub)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	mspID, adminID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get caller identity error: %s", err), Payload: nil}
+	}
+	if len(args) == 2 {
+		adminID = args[1]
+	} else if mspID != lib.SupplierMSP {
+		// 未指定初始管理员时由调用者担任，调用者需为供应商组织的成员
+		return pb.Response{Status: 400, Message: fmt.Sprintf("The caller is not a member of %s, the initial admin must be specified", lib.SupplierMSP), Payload: nil}
+	}
+
+	// 供应商名称不能重复
+	existing, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if existing != nil {
+		return pb.Response{Status: 400, Message: "The supplier already exists", Payload: nil}
+	}
+
+	// 创建供应商对象
+	supplier := &lib.Supplier{
+		SupplierName: supplierName,
+		Admins:       []string{adminID},
+		Quorum:       1,
+		State:        lib.Active,
+	}
+	// 记录初始管理员
+	err = appendAdminChange(stub, supplier, lib.AdminRegister, "", []string{adminID})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Record admin change error: %s", err), Payload: nil}
+	}
+	// 写入账本
+	err = putSupplier(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Register successful", Payload: nil}
+}
+
+// 查看供应商列表
+// 参数： 空
+// 返回： 按名称排序的供应商列表
+func (t *MedicalSystem) supplierList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 0 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 0", Payload: nil}
+	}
+
+	// 通过组合键前缀查询所有供应商，结果按 key 排序
+	iterator, err := stub.GetStateByPartialCompositeKey(lib.ObjectTypeSupplier, []string{})
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("GetStateByPartialCompositeKey error: %s", err), Payload: nil}
+	}
+	defer iterator.Close()
+	suppliersList := make([]lib.Supplier, 0)
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Iterator error: %s", err), Payload: nil}
+		}
+		var supplier lib.Supplier
+		err = json.Unmarshal(kv.Value, &supplier)
+		if err != nil {
+			return pb.Response{Status: 500, Message: fmt.Sprintf("Unmarshal error: %s", err), Payload: nil}
+		}
+		suppliersList = append(suppliersList, supplier)
+	}
+	// 序列化供应商列表
+	suppliersListJSON, err := json.Marshal(suppliersList)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Marshal error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "View successful", Payload: suppliersListJSON}
+}
+
+// 供应商停用
+// 参数： 供应商名称
+// 返回： 空
+func (t *MedicalSystem) supplierDeactivate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数个数
+	if len(args) != 1 {
+		return pb.Response{Status: 400, Message: "Incorrect number of arguments. Expecting 1", Payload: nil}
+	}
+	// 判断参数合法性（每个参数都不能为空）
+	if args[0] == "" {
+		return pb.Response{Status: 400, Message: "The parameter cannot be empty", Payload: nil}
+	}
+	supplierName := args[0]
+
+	// 读取账本，获取供应商
+	supplier, err := getSupplier(stub, supplierName)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Get supplier error: %s", err), Payload: nil}
+	} else if supplier == nil {
+		return pb.Response{Status: 400, Message: "The supplier does not exist", Payload: nil}
+	}
+	// 验证调用者为该供应商的管理员
+	err = checkSupplierAdmin(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 403, Message: fmt.Sprintf("Permission denied: %s", err), Payload: nil}
+	}
+	if supplier.State == lib.Inactive {
+		return pb.Response{Status: 400, Message: "The supplier has already been deactivated", Payload: nil}
+	}
+
+	// 修改供应商状态为停用
+	supplier.State = lib.Inactive
+	// 写入账本
+	err = putSupplier(stub, supplier)
+	if err != nil {
+		return pb.Response{Status: 500, Message: fmt.Sprintf("Put supplier error: %s", err), Payload: nil}
+	}
+
+	return pb.Response{Status: 200, Message: "Deactivate successful", Payload: nil}
+}
+
+// 验证调用者为该供应商的管理员
+func checkSupplierAdmin(stub shim.ChaincodeStubInterface, supplier *lib.Supplier) error {
+	mspID, callerID, err := utils.GetCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+	if mspID != lib.SupplierMSP || !utils.ContainsName(supplier.Admins, callerID) {
+		return fmt.Errorf("the caller is not an admin of supplier %s", supplier.SupplierName)
+	}
+	return nil
+}
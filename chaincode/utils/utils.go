@@ -2,10 +2,115 @@ package utils
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	"github.com/vendor-manage-inventory/chaincode/lib"
 )
 
-// ConstructSchemeKey 通过零售商名称构造补货方案的 key
-func ConstructSchemeKey(name string) string {
-	return fmt.Sprintf("Scheme-%s", name)
+// ConstructRetailerKey 通过零售商名称构造零售商的 key
+func ConstructRetailerKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeRetailer, []string{name})
+}
+
+// ConstructSchemeKey 通过零售商名称与补货方案序号构造补货方案的 key
+// 序号补零到固定宽度，使同一零售商的补货方案按序号排列
+func ConstructSchemeKey(stub shim.ChaincodeStubInterface, name string, sequence int) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeScheme, []string{name, fmt.Sprintf("%08d", sequence)})
+}
+
+// ConstructSupplierKey 通过供应商名称构造供应商的 key
+func ConstructSupplierKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeSupplier, []string{name})
+}
+
+// ConstructSupplierRetailerKey 通过供应商名称与零售商名称构造供应商负责补货的零售商索引的 key
+func ConstructSupplierRetailerKey(stub shim.ChaincodeStubInterface, supplierName string, retailerName string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeSupplierRetailer, []string{supplierName, retailerName})
+}
+
+// ConstructAdminProposalKey 通过供应商名称与提案 ID 构造管理员变更提案的 key
+func ConstructAdminProposalKey(stub shim.ChaincodeStubInterface, name string, proposalID string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeAdminProposal, []string{name, proposalID})
+}
+
+// ConstructAdminChangeKey 通过供应商名称与序号构造管理员变更记录的 key，序号补零以保证按序号排序
+func ConstructAdminChangeKey(stub shim.ChaincodeStubInterface, name string, sequence int) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeAdminChange, []string{name, fmt.Sprintf("%08d", sequence)})
+}
+
+// ConstructConfigKey 通过配置项名称构造配置的 key
+func ConstructConfigKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeConfig, []string{name})
+}
+
+// ConstructPriceBreaksKey 通过零售商名称构造价格折扣表的 key
+func ConstructPriceBreaksKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypePriceBreaks, []string{name})
 }
 
+// ConstructSupplierConfigKey 通过供应商名称构造供应商默认补货配置的 key
+func ConstructSupplierConfigKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeSupplierConfig, []string{name})
+}
+
+// ConstructRetailerConfigKey 通过零售商名称构造零售商补货配置覆盖的 key
+func ConstructRetailerConfigKey(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeRetailerConfig, []string{name})
+}
+
+// ConstructOrderKey 通过零售商名称与序号构造采购订单的 key，序号补零以保证按序号排序
+func ConstructOrderKey(stub shim.ChaincodeStubInterface, name string, sequence int) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeOrder, []string{name, fmt.Sprintf("%08d", sequence)})
+}
+
+// ConstructClaimKey 通过零售商名称与序号构造索赔的 key，序号补零以保证按序号排序
+func ConstructClaimKey(stub shim.ChaincodeStubInterface, name string, sequence int) (string, error) {
+	return stub.CreateCompositeKey(lib.ObjectTypeClaim, []string{name, fmt.Sprintf("%08d", sequence)})
+}
+
+// GetTxTime 获取交易时间戳，各背书节点得到的结果一致
+func GetTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+// GetCallerIdentity 从交易提交者的证书中获取调用者的 MSP ID 与身份 ID
+func GetCallerIdentity(stub cid.ChaincodeStubInterface) (string, string, error) {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", "", fmt.Errorf("get MSP ID error: %s", err)
+	}
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return "", "", fmt.Errorf("get client ID error: %s", err)
+	}
+	return mspID, id, nil
+}
+
+// SplitNames 将以逗号分隔的名称列表拆分为切片，去除空白与重复的名称
+func SplitNames(names string) []string {
+	var result []string
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !ContainsName(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// ContainsName 判断名称列表中是否包含指定名称
+func ContainsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -1,21 +1,106 @@
-# 零售商注册账号
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerRegistration","lingshou1","5","3","20","8","2","9","25.9","12","5"]}'
+# cli 默认以供应商 Admin@supplier.vmi.com 身份发起交易
+# 零售商相关的交易需要以零售商组织的身份发起，链码会校验交易提交者的证书
+RETAILER="-e CORE_PEER_LOCALMSPID=RetailerMSP -e CORE_PEER_ADDRESS=peer0.retailer.vmi.com:7051 -e CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/retailer/users/User1@retailer.vmi.com/msp"
+
+# 从旧版本链码升级后，需先由通道管理员一次性迁移账本数据（全新部署无需执行）
+# 旧版本的零售商没有记录注册身份，需为每个零售商指定所有者的客户端身份 ID，迁移后由该身份以零售商身份操作或添加成员
+# docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["migrateLedger","{\"lingshou1\":\"<零售商身份ID>\"}"]}'
+
+# 通道管理员（实例化或升级链码的身份，start.sh 中为 Admin@supplier.vmi.com）注册供应商，可指定初始管理员的身份 ID，默认为调用者
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierRegistration","supplierAdmin"]}'
+# 查看供应商列表
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierList"]}'
+# 函数按证书的 role 属性（auditor、warehouse、buyer、vmi-planner）授权，权限表见 viewPermissions
+# cryptogen 生成的证书没有属性，演示时由通道管理员放开限制；生产环境应使用 fabric-ca 签发带 role 属性的证书
+# 供应商管理员也可以修改权限表，需在最后附加供应商名称，如 ["setPermission","supplierViewSchemes","vmi-planner,auditor","supplierAdmin"]
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["viewPermissions"]}'
+for fn in retailerViewScheme retailerResponseScheme retailerUpdateInventory supplierAuditRegistration supplierViewSchemes supplierUpdateStock supplierAllocateStock supplierOverdueReports supplierShipOrder supplierDeliverOrder retailerReceiveOrder viewOrders retailerCounterScheme supplierCounterScheme supplierAcceptCounter supplierExpiringSchemes supplierExpireSchemes supplierResolveClaim supplierViewClaims viewClaims viewScheme viewSchemeHistory getRetailerHistory getSchemeHistory; do
+    docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["setPermission","'$fn'","*"]}'
+done
+# 供应商补货计算的默认配置（残值、默认策略及参数、取整规则、提前上报库存的处理方式），零售商可单独覆盖，修改记录可查
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierSetConfig","supplierAdmin","{\"residual_value\":2,\"rounding\":\"up\",\"rounding_multiple\":1,\"report_enforcement\":\"flag\",\"report_tolerance\":1}"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierViewConfig","supplierAdmin"]}'
+# 零售商注册账号（第 11 个参数为绑定的供应商名称列表，以逗号分隔；可再附加需求量标准差、目标服务水平百分比与提前期标准差，用于计算安全库存，最后可附加最大存储容量，省略或为 0 时不限制）
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerRegistration","lingshou1","5","3","20","8","2","9","25.9","12","5","supplierAdmin"]}'
+# 零售商的注册身份可以添加成员（如仓库、采购、审计人员各自的证书），成员按证书的角色代表该零售商操作
+# docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerAddMember","lingshou1","<成员身份ID>"]}'
+# docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerRemoveMember","lingshou1","<成员身份ID>"]}'
 # 供应商同意零售商注册
 docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAuditRegistration","supplierAdmin","lingshou1","1"]}'
+# 供应商更新库存量，零售商同意补货方案时从中扣减，可用库存不足时无法同意
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierUpdateStock","supplierAdmin","500"]}'
 # 零售商查看供应商补货方案
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou1"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou1"]}'
 # 零售商回应补货方案
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerResponseScheme","lingshou1","1"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerResponseScheme","lingshou1","1"]}'
+# 同意补货方案后创建采购订单（序号与补货方案相同），供应商发货、送达，零售商收货后才计入库存量
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierShipOrder","supplierAdmin","lingshou1","1"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierDeliverOrder","supplierAdmin","lingshou1","1"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerReceiveOrder","lingshou1","1"]}'
+# 收货时可记录到货、短缺与损坏的数量，只有完好的数量计入库存；有差异时创建索赔，由供应商确认（1，按索赔金额记录退款，缺少的数量由下次补货方案补足）或提出异议（0）
+# docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerReceiveOrder","lingshou1","<采购订单序号>","18","2","1"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierViewClaims","supplierAdmin"]}'
+# docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierResolveClaim","supplierAdmin","lingshou1","<索赔序号>","1","credit note issued"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["viewClaims","lingshou1"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["viewOrders","lingshou1"]}'
 # 零售商更新库存
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerUpdateInventory","lingshou1","51"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerUpdateInventory","lingshou1","51"]}'
+# 按上报周期检查库存上报：提前上报按配置标记或拒绝（report_enforcement），逾期上报会被标记；供应商可查询逾期未上报的零售商
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierOverdueReports","supplierAdmin"]}'
 # 供货商查看零售商们补货方案
 docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierViewSchemes","supplierAdmin"]}'
+# 查看零售商的全部补货方案，以及最新或指定 ID 的补货方案
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["viewSchemeHistory","lingshou1"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["viewScheme","lingshou1"]}'
+# docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["viewScheme","lingshou1","<补货方案ID>"]}'
+# 审计：查看零售商记录与补货方案的历史版本（交易 ID、时间、提交者身份），零售商组织的审计员需先由注册身份加入为成员
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["getRetailerHistory","lingshou1"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["getSchemeHistory","lingshou1"]}'
+# 为单个零售商覆盖补货配置，并查看生效的配置与修改记录
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierSetRetailerConfig","supplierAdmin","lingshou1","{\"rounding_multiple\":6}"]}'
+# 需求量均值由相邻两次库存上报之间的消耗量（扣除期间收到的补货）自动估计，默认按最近 4 条记录移动平均，可改为指数平滑
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierSetRetailerConfig","supplierAdmin","lingshou2","{\"demand_estimation\":\"exponential_smoothing\",\"smoothing_alpha\":0.4}"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierViewConfig","supplierAdmin","lingshou1"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierConfigHistory","supplierAdmin"]}'
+# 价格折扣表：补货方案按档位定价，增加订货到更高档位总价更低时按该档位订货
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierSetPriceBreaks","supplierAdmin","lingshou1","[{\"min_quantity\":50,\"unit_price\":4.5},{\"min_quantity\":100,\"unit_price\":4}]"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["viewPriceBreaks","lingshou1"]}'
 
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerRegistration","lingshou2","7","2","30","6","2","9","25.9","12","5"]}'
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAuditRegistration","supplierAdmin","lingshou2","1"]}'
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou2"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerRegistration","lingshou2","7","2","30","6","2","9","25.9","12","5","supplierAdmin","2","95","1","80"]}'
+# 审核通过时约定补货策略及参数，未指定时使用 (s,S) 策略
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAuditRegistration","supplierAdmin","lingshou2","1","minmax","{\"min\":10,\"max\":40}"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou2"]}'
 
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerRegistration","lingshou3","7","2","30","6","2","9","25.9","12","5"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerRegistration","lingshou3","7","2","30","6","2","9","25.9","12","5","supplierAdmin"]}'
 docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAuditRegistration","supplierAdmin","lingshou3","0"]}'
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou3"]}'
-docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou4"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou3"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerViewScheme","lingshou4"]}'
+
+# 供应商管理员变更（需达到法定人数的管理员批准，管理员以客户端身份 ID 表示，提案 7 天内未执行即过期，发起者可以撤回）
+# docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierProposeAdminChange","supplierAdmin","Add","<新管理员身份ID>"]}'
+# docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierApproveAdminChange","supplierAdmin","<提案ID>"]}'
+# docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierCancelAdminChange","supplierAdmin","<提案ID>"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierViewAdminProposals","supplierAdmin"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierViewAdminChanges","supplierAdmin"]}'
+
+# 零售商提出修改补货参数，供应商审核通过后生效
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerUpdateProfile","lingshou1","{\"lead_time\":4,\"review_cycle\":7,\"demand_std_dev\":2.5,\"service_level\":95,\"lead_time_std_dev\":1,\"storage_capacity\":120}"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAuditProfile","supplierAdmin","lingshou1","1"]}'
+# 审核补货参数修改时可重新约定补货策略（sS、RS、sQ、minmax、EOQ），EOQ 的补货方案中附带成本构成
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerUpdateProfile","lingshou2","{\"lead_time\":3}"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAuditProfile","supplierAdmin","lingshou2","1","EOQ"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerUpdateInventory","lingshou2","3"]}'
+# 库存不足时将库存配给所有待回应的补货方案（proportional 按比例、priority 按优先级、fillrate 按满足率）
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAllocateStock","supplierAdmin","fillrate"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAllocateStock","supplierAdmin","priority","{\"lingshou2\":2,\"lingshou1\":1}"]}'
+# 补货方案需在零售商的审查周期内回应，供应商可查询指定天数内即将过期的补货方案
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierExpiringSchemes","supplierAdmin","2"]}'
+# 将超过回应期限的补货方案标记为已过期并释放其占用的库存（回应已过期的补货方案会被拒绝）
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierExpireSchemes","supplierAdmin"]}'
+# 协商补货方案：零售商还价（补货数量、交货日期），供应商再次还价或同意还价，零售商可同意供应商的还价，约定的条件写入采购订单
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerCounterScheme","lingshou2","30","2030-01-15"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierCounterScheme","supplierAdmin","lingshou2","35","2030-01-20"]}'
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerCounterScheme","lingshou2","32","2030-01-18"]}'
+docker exec cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["supplierAcceptCounter","supplierAdmin","lingshou2"]}'
+# 被否决的零售商重新提交注册信息
+docker exec $RETAILER cli peer chaincode invoke -C vmichannel -n vmicc -c '{"Args":["retailerResubmit","lingshou3","7","2","30","6","2","9","25.9","12","5","supplierAdmin"]}'